@@ -0,0 +1,515 @@
+package blocktrade
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BALANCE_DRIFT_TOLERANCE is the difference below which the tracked and the
+// exchange balance are considered equal.
+const BALANCE_DRIFT_TOLERANCE = 1e-9
+
+// BALANCE_TRACKER_REPLAY_MARGIN is how much further back than the seed the
+// trade stream is replayed on Subscribe, covering the time the subscription
+// takes. Trades dated before the seed are ignored.
+const BALANCE_TRACKER_REPLAY_MARGIN = time.Minute
+
+type TrackedBalance struct {
+	TradingAssetId int64
+	Available      float64
+	Reserved       float64
+}
+
+func (b TrackedBalance) Total() float64 {
+	return b.Available + b.Reserved
+}
+
+// BalanceDrift is the difference between the tracked balance and the one
+// reported by the exchange, positive if the tracker holds more.
+type BalanceDrift struct {
+	TradingAssetId int64
+	Available      float64
+	Reserved       float64
+}
+
+type BalanceDriftHandlerFunc func(drifts []*BalanceDrift)
+
+// trackedOrder is an order of the tracked portfolio. Orders that are done
+// are kept until the next reconcile so that their late trades still match.
+type trackedOrder struct {
+	assetId  int64
+	reserved float64
+	done     bool
+	seen     time.Time
+}
+
+// trackedTrade is a parsed trade, amounts in the base and value and fee in
+// the quote asset.
+type trackedTrade struct {
+	id           int64
+	orderId      int64
+	date         int64
+	baseAssetId  int64
+	quoteAssetId int64
+	direction    Direction
+	amount       float64
+	value        float64
+	fee          float64
+}
+
+type balanceDelta struct {
+	assetId   int64
+	available float64
+	reserved  float64
+}
+
+// BalanceTracker keeps a portfolio's balances current from the user_orders
+// and user_trades streams. Orders reserve what they may spend and release it
+// when they fill or are cancelled, trades move the filled amounts and fees.
+// Only trades of orders seen in the tracked portfolio are applied, trades
+// arriving before their order are held until it is seen. Fees are assumed
+// to be charged in the quote asset. Reconcile corrects the model from the
+// REST API. It is safe for concurrent use.
+type BalanceTracker struct {
+	client      *APIClient
+	portfolioId int64
+
+	reconcileMtx sync.Mutex
+
+	mtx      sync.Mutex
+	seeded   time.Time
+	balances map[int64]*TrackedBalance
+	orders   map[int64]*trackedOrder
+	foreign  map[int64]time.Time
+	pending  map[int64][]*trackedTrade
+	trades   map[int64]int64
+	journal  []balanceDelta
+	onDrift  BalanceDriftHandlerFunc
+}
+
+func NewBalanceTracker(client *APIClient, portfolioId int64) *BalanceTracker {
+	return &BalanceTracker{
+		client:      client,
+		portfolioId: portfolioId,
+		balances:    make(map[int64]*TrackedBalance),
+		orders:      make(map[int64]*trackedOrder),
+		foreign:     make(map[int64]time.Time),
+		pending:     make(map[int64][]*trackedTrade),
+		trades:      make(map[int64]int64),
+	}
+}
+
+// OnDrift registers f to be called by Reconcile when the model differed
+// from the exchange.
+func (t *BalanceTracker) OnDrift(f BalanceDriftHandlerFunc) {
+	t.mtx.Lock()
+	t.onDrift = f
+	t.mtx.Unlock()
+}
+
+// Seed loads the balances from the REST API.
+func (t *BalanceTracker) Seed(ctx context.Context) error {
+	_, err := t.Reconcile(ctx)
+	return err
+}
+
+// Subscribe subscribes the tracker to the user order stream, seeds it and
+// subscribes it to the user trade stream replayed from the seed, so that no
+// trade in between is missed. It replaces other handlers for these streams.
+func (t *BalanceTracker) Subscribe(ctx context.Context) error {
+	err := t.client.SubscribeUserOrders(t.OrderHandler())
+	if err != nil {
+		return err
+	}
+
+	err = t.Seed(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	seeded := t.seeded
+	t.mtx.Unlock()
+
+	return t.client.SubscribeUserTrades(time.Since(seeded)+BALANCE_TRACKER_REPLAY_MARGIN, t.TradeHandler())
+}
+
+// Start reconciles every interval until ctx is done.
+func (t *BalanceTracker) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.Reconcile(ctx)
+			}
+		}
+	}()
+}
+
+// Reconcile fetches the portfolio, reports the drift of the model and
+// resets the model to the exchange's balances. The balances are taken as of
+// the start of the request: changes applied while it runs are replayed on
+// top of them and trades dated earlier are ignored from then on.
+func (t *BalanceTracker) Reconcile(ctx context.Context) ([]*BalanceDrift, error) {
+	t.reconcileMtx.Lock()
+	defer t.reconcileMtx.Unlock()
+
+	start := time.Now()
+	t.mtx.Lock()
+	t.journal = make([]balanceDelta, 0)
+	t.mtx.Unlock()
+
+	balances, err := t.fetchBalances(ctx)
+	if err != nil {
+		t.mtx.Lock()
+		t.journal = nil
+		t.mtx.Unlock()
+		return nil, err
+	}
+
+	t.mtx.Lock()
+	for _, delta := range t.journal {
+		b := balances[delta.assetId]
+		if b == nil {
+			b = &TrackedBalance{TradingAssetId: delta.assetId}
+			balances[delta.assetId] = b
+		}
+		b.Available += delta.available
+		b.Reserved += delta.reserved
+	}
+
+	drifts := make([]*BalanceDrift, 0)
+	if !t.seeded.IsZero() {
+		drifts = balanceDrifts(t.balances, balances)
+	}
+
+	previous := t.seeded
+	t.balances = balances
+	t.seeded = start
+	t.journal = nil
+	t.prune(previous)
+	onDrift := t.onDrift
+	t.mtx.Unlock()
+
+	if onDrift != nil && len(drifts) > 0 {
+		onDrift(drifts)
+	}
+
+	return drifts, nil
+}
+
+func (t *BalanceTracker) fetchBalances(ctx context.Context) (map[int64]*TrackedBalance, error) {
+	portfolio, err := t.client.GetPortfolio(ctx, t.portfolioId)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[int64]*TrackedBalance)
+	for _, asset := range portfolio.Assets {
+		available, err := parseOptionalFloat(asset.AvailableAmount)
+		if err != nil {
+			return nil, err
+		}
+
+		reserved, err := parseOptionalFloat(asset.ReservedAmount)
+		if err != nil {
+			return nil, err
+		}
+
+		balances[asset.TradingAssetId] = &TrackedBalance{
+			TradingAssetId: asset.TradingAssetId,
+			Available:      available,
+			Reserved:       reserved,
+		}
+	}
+
+	return balances, nil
+}
+
+// prune drops trades included in the seeded balances and orders that were
+// done, or seen in other portfolios, before the previous seed.
+func (t *BalanceTracker) prune(previous time.Time) {
+	seeded := t.seeded.UnixMilli()
+	for id, date := range t.trades {
+		if date < seeded {
+			delete(t.trades, id)
+		}
+	}
+
+	for id, trades := range t.pending {
+		kept := trades[:0]
+		for _, trade := range trades {
+			if trade.date >= seeded {
+				kept = append(kept, trade)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(t.pending, id)
+		} else {
+			t.pending[id] = kept
+		}
+	}
+
+	for id, order := range t.orders {
+		if order.done && order.seen.Before(previous) {
+			delete(t.orders, id)
+		}
+	}
+
+	for id, seen := range t.foreign {
+		if seen.Before(previous) {
+			delete(t.foreign, id)
+		}
+	}
+}
+
+func balanceDrifts(model, exchange map[int64]*TrackedBalance) []*BalanceDrift {
+	ids := make(map[int64]bool)
+	for id := range model {
+		ids[id] = true
+	}
+	for id := range exchange {
+		ids[id] = true
+	}
+
+	drifts := make([]*BalanceDrift, 0)
+	for id := range ids {
+		var m, e TrackedBalance
+		if b, ok := model[id]; ok {
+			m = *b
+		}
+		if b, ok := exchange[id]; ok {
+			e = *b
+		}
+
+		drift := &BalanceDrift{
+			TradingAssetId: id,
+			Available:      m.Available - e.Available,
+			Reserved:       m.Reserved - e.Reserved,
+		}
+
+		if math.Abs(drift.Available) > BALANCE_DRIFT_TOLERANCE || math.Abs(drift.Reserved) > BALANCE_DRIFT_TOLERANCE {
+			drifts = append(drifts, drift)
+		}
+	}
+
+	sort.Slice(drifts, func(i, j int) bool {
+		return drifts[i].TradingAssetId < drifts[j].TradingAssetId
+	})
+
+	return drifts
+}
+
+// Balance returns the tracked balance of an asset.
+func (t *BalanceTracker) Balance(tradingAssetId int64) TrackedBalance {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if b, ok := t.balances[tradingAssetId]; ok {
+		return *b
+	}
+
+	return TrackedBalance{TradingAssetId: tradingAssetId}
+}
+
+// Balances returns all tracked balances sorted by asset id.
+func (t *BalanceTracker) Balances() []TrackedBalance {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	balances := make([]TrackedBalance, 0, len(t.balances))
+	for _, b := range t.balances {
+		balances = append(balances, *b)
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].TradingAssetId < balances[j].TradingAssetId
+	})
+
+	return balances
+}
+
+func (t *BalanceTracker) OrderHandler() UserOrderHandlerFunc {
+	return func(order *OrderResponse, err error) {
+		if err != nil {
+			return
+		}
+
+		t.ApplyOrder(order)
+	}
+}
+
+func (t *BalanceTracker) TradeHandler() UserTradeHandlerFunc {
+	return func(trade *TradeResponse, err error) {
+		if err != nil {
+			return
+		}
+
+		t.ApplyTrade(trade)
+	}
+}
+
+// ApplyOrder updates the reservation of order. Orders placed before the
+// last seed are already part of the seeded reserved amounts.
+func (t *BalanceTracker) ApplyOrder(order *OrderResponse) error {
+	if order.PortfolioId != t.portfolioId {
+		t.mtx.Lock()
+		t.foreign[order.Id] = time.Now()
+		delete(t.pending, order.Id)
+		t.mtx.Unlock()
+		return nil
+	}
+
+	pair, err := t.client.TradingPairFromId(order.TradingPairId)
+	if err != nil {
+		return err
+	}
+
+	remaining, err := parseOptionalFloat(order.RemainingAmount)
+	if err != nil {
+		return err
+	}
+
+	price, err := parseOptionalFloat(order.Price)
+	if err != nil {
+		return err
+	}
+
+	assetId := pair.BaseAssetId
+	reserve := remaining
+	if order.Direction == Direction_BUY {
+		assetId = pair.QuoteAssetId
+		reserve = remaining * price
+	}
+
+	if order.Status == Status_FILLED || order.Status == Status_CANCELLED {
+		reserve = 0
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	tracked, ok := t.orders[order.Id]
+	if !ok {
+		tracked = &trackedOrder{assetId: assetId}
+		t.orders[order.Id] = tracked
+
+		if time.UnixMilli(order.Date).Before(t.seeded) {
+			// already reserved when the balances were seeded
+			tracked.reserved = reserve
+		}
+	}
+
+	released := tracked.reserved - reserve
+	t.move(assetId, released, -released)
+	tracked.reserved = reserve
+	tracked.done = reserve == 0 && order.Status != Status_NEW
+	tracked.seen = time.Now()
+
+	for _, trade := range t.pending[order.Id] {
+		t.applyTrade(trade)
+	}
+	delete(t.pending, order.Id)
+
+	return nil
+}
+
+// ApplyTrade moves the traded amounts and the fee between the base and the
+// quote balance. Trades are applied once, trades dated before the last seed
+// are already part of the seeded balances.
+func (t *BalanceTracker) ApplyTrade(trade *TradeResponse) error {
+	pair, err := t.client.TradingPairFromId(trade.TradingPairId)
+	if err != nil {
+		return err
+	}
+
+	amount, err := parseOptionalFloat(trade.Amount)
+	if err != nil {
+		return err
+	}
+
+	value, err := tradeNotional(trade)
+	if err != nil {
+		return err
+	}
+
+	fee, err := parseOptionalFloat(trade.FeeValue)
+	if err != nil {
+		return err
+	}
+
+	tracked := &trackedTrade{
+		id:           trade.Id,
+		orderId:      trade.OrderId,
+		date:         trade.Date,
+		baseAssetId:  pair.BaseAssetId,
+		quoteAssetId: pair.QuoteAssetId,
+		direction:    trade.Direction,
+		amount:       amount,
+		value:        value,
+		fee:          fee,
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if _, ok := t.orders[trade.OrderId]; ok {
+		t.applyTrade(tracked)
+		return nil
+	}
+
+	if _, ok := t.foreign[trade.OrderId]; !ok {
+		t.pending[trade.OrderId] = append(t.pending[trade.OrderId], tracked)
+	}
+
+	return nil
+}
+
+func (t *BalanceTracker) applyTrade(trade *trackedTrade) {
+	if trade.date < t.seeded.UnixMilli() {
+		return
+	}
+
+	if _, ok := t.trades[trade.id]; ok {
+		return
+	}
+	t.trades[trade.id] = trade.date
+
+	if trade.direction == Direction_BUY {
+		t.move(trade.baseAssetId, trade.amount, 0)
+		t.move(trade.quoteAssetId, -(trade.value + trade.fee), 0)
+	} else {
+		t.move(trade.baseAssetId, -trade.amount, 0)
+		t.move(trade.quoteAssetId, trade.value-trade.fee, 0)
+	}
+}
+
+// move changes a balance and records the change while a reconcile is
+// fetching.
+func (t *BalanceTracker) move(tradingAssetId int64, available, reserved float64) {
+	b, ok := t.balances[tradingAssetId]
+	if !ok {
+		b = &TrackedBalance{TradingAssetId: tradingAssetId}
+		t.balances[tradingAssetId] = b
+	}
+
+	b.Available += available
+	b.Reserved += reserved
+
+	if t.journal != nil {
+		t.journal = append(t.journal, balanceDelta{
+			assetId:   tradingAssetId,
+			available: available,
+			reserved:  reserved,
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package blocktrade
+
+import (
+	"context"
+	"sort"
+)
+
+// Balance is a portfolio's holding of one asset.
+type Balance struct {
+	Asset         *TradingAsset
+	Available     float64
+	Reserved      float64
+	Total         float64
+	WalletAddress string
+}
+
+// Balance returns the default portfolio's balance of the asset with
+// isoCode. Assets missing from the portfolio have a zero balance.
+func (a *APIClient) Balance(ctx context.Context, isoCode string) (*Balance, error) {
+	asset, err := a.TradingAssetFromCode(isoCode)
+	if err != nil {
+		return nil, err
+	}
+
+	balances, err := a.Balances(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, balance := range balances {
+		if balance.Asset.Id == asset.Id {
+			return balance, nil
+		}
+	}
+
+	return &Balance{Asset: asset}, nil
+}
+
+// Balances returns the default portfolio's balances sorted by iso code.
+// If hideZero is set, assets with a zero total are left out.
+func (a *APIClient) Balances(ctx context.Context, hideZero bool) ([]*Balance, error) {
+	portfolioId, err := a.GetPortfolioId()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.PortfolioBalances(ctx, portfolioId, hideZero)
+}
+
+// PortfolioBalances is like Balances for the given portfolio.
+func (a *APIClient) PortfolioBalances(ctx context.Context, portfolioId int64, hideZero bool) ([]*Balance, error) {
+	portfolio, err := a.GetPortfolio(ctx, portfolioId)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]*Balance, 0, len(portfolio.Assets))
+	for _, portfolioAsset := range portfolio.Assets {
+		balance, err := a.balance(portfolioAsset)
+		if err != nil {
+			return nil, err
+		}
+
+		if hideZero && balance.Total == 0 {
+			continue
+		}
+
+		balances = append(balances, balance)
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Asset.IsoCode < balances[j].Asset.IsoCode
+	})
+
+	return balances, nil
+}
+
+func (a *APIClient) balance(portfolioAsset *PortfolioAsset) (*Balance, error) {
+	asset, err := a.TradingAssetFromId(portfolioAsset.TradingAssetId)
+	if err != nil {
+		return nil, err
+	}
+
+	available, err := parseOptionalFloat(portfolioAsset.AvailableAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	reserved, err := parseOptionalFloat(portfolioAsset.ReservedAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Balance{
+		Asset:         asset,
+		Available:     available,
+		Reserved:      reserved,
+		Total:         available + reserved,
+		WalletAddress: portfolioAsset.WalletAddress,
+	}, nil
+}
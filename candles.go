@@ -0,0 +1,269 @@
+package blocktrade
+
+import (
+	"sync"
+	"time"
+)
+
+const CandleInterval_1m = time.Minute
+const CandleInterval_5m = 5 * time.Minute
+const CandleInterval_1h = time.Hour
+const CandleInterval_1d = 24 * time.Hour
+
+// Candle is an OHLCV bar starting at Start. Volume is in the base asset and
+// only counts trades since tickers carry no per-bar volume. Filled marks a
+// bar without any updates that was filled with the previous close.
+type Candle struct {
+	TradingPairId int64
+	Interval      time.Duration
+	Start         time.Time
+	Open          float64
+	High          float64
+	Low           float64
+	Close         float64
+	Volume        float64
+	Trades        int
+	Filled        bool
+}
+
+func (c *Candle) End() time.Time {
+	return c.Start.Add(c.Interval)
+}
+
+type CandleHandlerFunc func(candle *Candle)
+
+type candleKey struct {
+	tradingPairId int64
+	interval      time.Duration
+}
+
+// CandleAggregator builds candles per trading pair and interval from ticker
+// and trade updates and calls a handler when a candle closes. It is safe for
+// concurrent use.
+type CandleAggregator struct {
+	mtx       sync.Mutex
+	intervals []time.Duration
+	fillGaps  bool
+	onClose   CandleHandlerFunc
+	current   map[candleKey]*Candle
+}
+
+// NewCandleAggregator returns an aggregator for the given intervals calling
+// onClose for every closed candle. If fillGaps is set, intervals without
+// updates produce flat candles at the previous close.
+func NewCandleAggregator(onClose CandleHandlerFunc, fillGaps bool, intervals ...time.Duration) *CandleAggregator {
+	return &CandleAggregator{
+		intervals: intervals,
+		fillGaps:  fillGaps,
+		onClose:   onClose,
+		current:   make(map[candleKey]*Candle),
+	}
+}
+
+// AddTicker adds the ticker's last price at time at.
+func (c *CandleAggregator) AddTicker(ticker *TickerResponse, at time.Time) error {
+	price, err := parseOptionalFloat(ticker.Data.LastPrice)
+	if err != nil {
+		return err
+	}
+
+	if price == 0 {
+		return nil
+	}
+
+	c.add(ticker.TradingPairId, price, 0, at)
+	return nil
+}
+
+// AddTrade adds a trade at its price, amount and date.
+func (c *CandleAggregator) AddTrade(trade *TradeResponse) error {
+	price, err := parseOptionalFloat(trade.Price)
+	if err != nil {
+		return err
+	}
+
+	amount, err := parseOptionalFloat(trade.Amount)
+	if err != nil {
+		return err
+	}
+
+	c.add(trade.TradingPairId, price, amount, time.UnixMilli(trade.Date))
+	return nil
+}
+
+// AddPublicTrade adds a public trade at its price, amount and date.
+func (c *CandleAggregator) AddPublicTrade(trade *PublicTradeResponse) error {
+	price, err := parseOptionalFloat(trade.Price)
+	if err != nil {
+		return err
+	}
+
+	amount, err := parseOptionalFloat(trade.Amount)
+	if err != nil {
+		return err
+	}
+
+	c.add(trade.TradingPairId, price, amount, time.UnixMilli(trade.Date))
+	return nil
+}
+
+// TickerHandler returns a handler for SubscribeTicker feeding the
+// aggregator with the time of arrival.
+func (c *CandleAggregator) TickerHandler() TickerHandlerFunc {
+	return func(ticker *TickerResponse, err error) {
+		if err != nil {
+			return
+		}
+
+		c.AddTicker(ticker, time.Now())
+	}
+}
+
+// UserTradeHandler returns a handler for SubscribeUserTrades feeding the
+// aggregator.
+func (c *CandleAggregator) UserTradeHandler() UserTradeHandlerFunc {
+	return func(trade *TradeResponse, err error) {
+		if err != nil {
+			return
+		}
+
+		c.AddTrade(trade)
+	}
+}
+
+// PublicTradeHandler returns a handler for SubscribePublicTrades feeding
+// the aggregator.
+func (c *CandleAggregator) PublicTradeHandler() PublicTradeHandlerFunc {
+	return func(trade *PublicTradeResponse, err error) {
+		if err != nil {
+			return
+		}
+
+		c.AddPublicTrade(trade)
+	}
+}
+
+// Current returns a copy of the open candle, or nil if there is none.
+func (c *CandleAggregator) Current(tradingPairId int64, interval time.Duration) *Candle {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	candle, ok := c.current[candleKey{tradingPairId, interval}]
+	if !ok {
+		return nil
+	}
+
+	cpy := *candle
+	return &cpy
+}
+
+// Flush closes all candles that ended before now. Call it periodically to
+// close candles of pairs that receive no updates.
+func (c *CandleAggregator) Flush(now time.Time) {
+	c.mtx.Lock()
+	closed := make([]*Candle, 0)
+	for key, candle := range c.current {
+		if candle.End().After(now) {
+			continue
+		}
+
+		closed = append(closed, c.roll(key, candle, now)...)
+	}
+	c.mtx.Unlock()
+
+	c.emit(closed)
+}
+
+func (c *CandleAggregator) add(tradingPairId int64, price, amount float64, at time.Time) {
+	c.mtx.Lock()
+	closed := make([]*Candle, 0)
+	for _, interval := range c.intervals {
+		key := candleKey{tradingPairId, interval}
+		candle, ok := c.current[key]
+		if ok && !at.Before(candle.End()) {
+			closed = append(closed, c.roll(key, candle, at)...)
+			candle, ok = c.current[key]
+		}
+
+		if !ok {
+			candle = &Candle{
+				TradingPairId: tradingPairId,
+				Interval:      interval,
+				Start:         at.Truncate(interval),
+				Open:          price,
+				High:          price,
+				Low:           price,
+			}
+			c.current[key] = candle
+		}
+
+		// late updates for an already closed candle are dropped
+		if at.Before(candle.Start) {
+			continue
+		}
+
+		if candle.Filled {
+			candle.Filled = false
+			candle.Open = price
+			candle.High = price
+			candle.Low = price
+		}
+
+		if price > candle.High {
+			candle.High = price
+		}
+		if price < candle.Low {
+			candle.Low = price
+		}
+		candle.Close = price
+
+		if amount > 0 {
+			candle.Volume += amount
+			candle.Trades++
+		}
+	}
+	c.mtx.Unlock()
+
+	c.emit(closed)
+}
+
+// roll closes candle and, with gap filling, opens flat candles up to the one
+// containing at. The candle containing at stays open.
+func (c *CandleAggregator) roll(key candleKey, candle *Candle, at time.Time) []*Candle {
+	closed := []*Candle{candle}
+	delete(c.current, key)
+
+	if !c.fillGaps {
+		return closed
+	}
+
+	for start := candle.End(); ; start = start.Add(key.interval) {
+		filled := &Candle{
+			TradingPairId: key.tradingPairId,
+			Interval:      key.interval,
+			Start:         start,
+			Open:          candle.Close,
+			High:          candle.Close,
+			Low:           candle.Close,
+			Close:         candle.Close,
+			Filled:        true,
+		}
+
+		if at.Before(filled.End()) {
+			c.current[key] = filled
+			return closed
+		}
+
+		closed = append(closed, filled)
+	}
+}
+
+func (c *CandleAggregator) emit(closed []*Candle) {
+	if c.onClose == nil {
+		return
+	}
+
+	for _, candle := range closed {
+		c.onClose(candle)
+	}
+}
@@ -15,8 +15,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 var Debug = false
@@ -27,17 +25,29 @@ type APIClient struct {
 	apiKey    string
 	apiSecret string
 
-	assetCache map[int64]*TradingAsset
-	pairCache  map[int64]*TradingPair
-	portfolio  *Portfolio
+	metadata *metadataCache
+
+	portfolioMtx sync.Mutex
+	portfolioId  int64
+
+	nonceMtx       sync.Mutex
+	wsConnMtx      sync.Mutex
+	wsMtx          sync.Mutex
+	ws             *wsSession
+	wsHandlers     map[MessageType]interface{}
+	wsHandlerMtx   sync.Mutex
+	wsOrderBooks   map[int64]*orderBookSubscription
+	wsPublicTrades map[int64]*publicTradesSubscription
 
-	nonceMtx     sync.Mutex
-	wsConn       *websocket.Conn
-	wsHandlers   map[MessageType]interface{}
-	wsHandlerMtx sync.Mutex
+	wsRawHandler     RawMessageHandlerFunc
+	wsUnknownHandler UnknownMessageHandlerFunc
 
-	pingCtx    context.Context
-	pingCancel context.CancelFunc
+	wsAckMtx     sync.Mutex
+	wsAckWaiters map[string][]chan error
+
+	pingCancel     context.CancelFunc
+	wsPingInterval time.Duration
+	wsPongTimeout  time.Duration
 }
 
 type APIError struct {
@@ -62,22 +72,25 @@ func NewClient(apiKey, apiSecret string) *APIClient {
 		apiKey:    apiKey,
 		apiSecret: apiSecret,
 
-		assetCache: make(map[int64]*TradingAsset),
-		pairCache:  make(map[int64]*TradingPair),
+		metadata: newMetadataCache(),
+
+		wsHandlers:     make(map[MessageType]interface{}),
+		wsOrderBooks:   make(map[int64]*orderBookSubscription),
+		wsPublicTrades: make(map[int64]*publicTradesSubscription),
+		wsAckWaiters:   make(map[string][]chan error),
 
-		wsHandlers: make(map[MessageType]interface{}),
+		wsPingInterval: WS_PING_INTERVAL,
+		wsPongTimeout:  WS_PONG_TIMEOUT,
 	}
 }
 
+// Close closes the websocket connection and waits for its goroutines to
+// exit. It may be called multiple times but not from a websocket handler.
 func (a *APIClient) Close() {
-	if a.wsConn != nil {
-		a.wsConn.Close()
-		a.wsConn = nil
-	}
+	a.wsConnMtx.Lock()
+	defer a.wsConnMtx.Unlock()
 
-	if a.pingCancel != nil {
-		a.pingCancel()
-	}
+	a.closeWs()
 }
 
 const API_URL = "https://trade.blocktrade.com/api/v1"
@@ -105,9 +118,9 @@ func (a *APIClient) nonceAndSignature(request interface{}) (int64, string, error
 	return nonce, sha, nil
 }
 
-func (a *APIClient) requestPublicGET(endpoint string) ([]byte, error) {
+func (a *APIClient) requestPublicGETContext(ctx context.Context, endpoint string) ([]byte, error) {
 	url := fmt.Sprintf("%v%v", API_URL, endpoint)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -116,6 +129,10 @@ func (a *APIClient) requestPublicGET(endpoint string) ([]byte, error) {
 }
 
 func (a *APIClient) requestPOST(endpoint string, request interface{}) ([]byte, error) {
+	return a.requestPOSTContext(context.Background(), endpoint, request)
+}
+
+func (a *APIClient) requestPOSTContext(ctx context.Context, endpoint string, request interface{}) ([]byte, error) {
 	if a.apiKey == "" || a.apiSecret == "" {
 		return nil, errors.New("missing credentials")
 	}
@@ -135,7 +152,7 @@ func (a *APIClient) requestPOST(endpoint string, request interface{}) ([]byte, e
 	body := bytes.NewReader(b)
 	url := fmt.Sprintf("%v%v", API_URL, endpoint)
 
-	req, err := http.NewRequest("POST", url, body)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -149,10 +166,14 @@ func (a *APIClient) requestPOST(endpoint string, request interface{}) ([]byte, e
 }
 
 func (a *APIClient) requestGET(endpoint string) ([]byte, error) {
-	return a.requestNoBody(endpoint, "GET")
+	return a.requestNoBody(context.Background(), endpoint, "GET")
+}
+
+func (a *APIClient) requestGETContext(ctx context.Context, endpoint string) ([]byte, error) {
+	return a.requestNoBody(ctx, endpoint, "GET")
 }
 
-func (a *APIClient) requestNoBody(endpoint string, method string) ([]byte, error) {
+func (a *APIClient) requestNoBody(ctx context.Context, endpoint string, method string) ([]byte, error) {
 	if a.apiKey == "" || a.apiSecret == "" {
 		return nil, errors.New("missing credentials")
 	}
@@ -165,7 +186,7 @@ func (a *APIClient) requestNoBody(endpoint string, method string) ([]byte, error
 	}
 
 	url := fmt.Sprintf("%v%v", API_URL, endpoint)
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
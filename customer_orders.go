@@ -1,6 +1,7 @@
 package blocktrade
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -69,7 +70,20 @@ type OrderTradeResponse struct {
 	Time  int64  `json:"time"`
 }
 
+// CreateCustomerOrder places an order. Orders without PortfolioId go to the
+// default portfolio.
 func (a *APIClient) CreateCustomerOrder(request *CustomerOrderRequest) (*CreateOrderResponse, error) {
+	if request.PortfolioId == 0 {
+		portfolioId, err := a.GetPortfolioId()
+		if err != nil {
+			return nil, err
+		}
+
+		withPortfolio := *request
+		withPortfolio.PortfolioId = portfolioId
+		request = &withPortfolio
+	}
+
 	b, err := a.requestPOST(CUSTOMER_ORDERS_ENDPOINT, request)
 	if err != nil {
 		return nil, err
@@ -94,6 +108,6 @@ func (a *APIClient) GetCustomerOrder(customerOrderId string) (*OrderResponse, er
 
 func (a *APIClient) CancelCustomerOrder(customerOrderId string) error {
 	url := fmt.Sprintf("%v/%v/cancel", CUSTOMER_ORDERS_ENDPOINT, customerOrderId)
-	_, err := a.requestNoBody(url, "POST")
+	_, err := a.requestNoBody(context.Background(), url, "POST")
 	return err
 }
@@ -0,0 +1,162 @@
+package blocktrade
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// The deposit endpoints and schemas below are not covered by the API
+// documentation this client is built from and are unverified.
+const DEPOSITS_ENDPOINT = "/deposits"
+const DEPOSIT_ADDRESS_ENDPOINT = "/deposit_address"
+const DEPOSIT_INSTRUCTIONS_ENDPOINT = "/deposit_instructions"
+
+var ErrDepositMethodNotSupported = errors.New("deposit method not supported for asset")
+
+type DepositStatus string
+
+const DepositStatus_PENDING DepositStatus = "PENDING"
+const DepositStatus_CONFIRMING DepositStatus = "CONFIRMING"
+const DepositStatus_COMPLETED DepositStatus = "COMPLETED"
+const DepositStatus_REJECTED DepositStatus = "REJECTED"
+
+// Final reports whether the deposit will not change status anymore.
+func (s DepositStatus) Final() bool {
+	return s == DepositStatus_COMPLETED || s == DepositStatus_REJECTED
+}
+
+type DepositAddress struct {
+	PortfolioId    int64  `json:"portfolio_id"`
+	TradingAssetId int64  `json:"trading_asset_id"`
+	Address        string `json:"address"`
+	Tag            string `json:"tag"`
+}
+
+type depositAddressRequest struct {
+	PortfolioId    int64 `json:"portfolio_id"`
+	TradingAssetId int64 `json:"trading_asset_id"`
+}
+
+// DepositResponse is a deposit to the account. Confirmations counts the
+// blockchain confirmations of crypto deposits so far, the deposit is
+// credited once RequiredConfirmations are reached.
+type DepositResponse struct {
+	Id                    int64         `json:"id"`
+	PortfolioId           int64         `json:"portfolio_id"`
+	TradingAssetId        int64         `json:"trading_asset_id"`
+	Method                DepositMethod `json:"method"`
+	Amount                string        `json:"amount"`
+	Fee                   string        `json:"fee"`
+	Address               string        `json:"address"`
+	TransactionId         string        `json:"transaction_id"`
+	Confirmations         int64         `json:"confirmations"`
+	RequiredConfirmations int64         `json:"required_confirmations"`
+	Status                DepositStatus `json:"status"`
+	Date                  int64         `json:"date"`
+}
+
+// SepaDepositInstructions are the bank details to transfer fiat to. The
+// Reference must be included with the transfer for it to be credited.
+type SepaDepositInstructions struct {
+	TradingAssetId  int64  `json:"trading_asset_id"`
+	BeneficiaryName string `json:"beneficiary_name"`
+	Iban            string `json:"iban"`
+	Bic             string `json:"bic"`
+	BankName        string `json:"bank_name"`
+	BankAddress     string `json:"bank_address"`
+	Reference       string `json:"reference"`
+}
+
+// DepositAddress requests the portfolio's deposit address and tag for an
+// asset, generating one if the portfolio has none yet. A zero portfolioId
+// uses the default portfolio. As the endpoint is unverified, it may also
+// generate a new address on every call. The portfolio's WalletAddress is
+// not used since it carries no tag, and assets needing one cannot be told
+// apart, so a deposit to it could be lost.
+func (a *APIClient) DepositAddress(ctx context.Context, portfolioId int64, tradingAssetId int64) (*DepositAddress, error) {
+	err := a.checkDepositMethod(tradingAssetId, DepositMethod_WALLET_ADDRESS)
+	if err != nil {
+		return nil, err
+	}
+
+	if portfolioId == 0 {
+		portfolioId, err = a.GetPortfolioId()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	request := &depositAddressRequest{
+		PortfolioId:    portfolioId,
+		TradingAssetId: tradingAssetId,
+	}
+
+	b, err := a.requestPOSTContext(ctx, DEPOSIT_ADDRESS_ENDPOINT, request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(DepositAddress)
+	err = json.Unmarshal(b, &resp)
+	return resp, err
+}
+
+// Deposits lists the deposits of the account.
+func (a *APIClient) Deposits(ctx context.Context) ([]*DepositResponse, error) {
+	b, err := a.requestGETContext(ctx, DEPOSITS_ENDPOINT)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*DepositResponse, 0)
+	err = json.Unmarshal(b, &resp)
+	return resp, err
+}
+
+func (a *APIClient) GetDeposit(ctx context.Context, id int64) (*DepositResponse, error) {
+	url := fmt.Sprintf("%v/%v", DEPOSITS_ENDPOINT, id)
+	b, err := a.requestGETContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(DepositResponse)
+	err = json.Unmarshal(b, &resp)
+	return resp, err
+}
+
+// SepaDepositInstructions returns the bank details for a SEPA deposit of a
+// fiat asset.
+func (a *APIClient) SepaDepositInstructions(ctx context.Context, tradingAssetId int64) (*SepaDepositInstructions, error) {
+	err := a.checkDepositMethod(tradingAssetId, DepositMethod_CLEAR_JUNCTION_SEPA)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%v/%v", DEPOSIT_INSTRUCTIONS_ENDPOINT, tradingAssetId)
+	b, err := a.requestGETContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(SepaDepositInstructions)
+	err = json.Unmarshal(b, &resp)
+	return resp, err
+}
+
+func (a *APIClient) checkDepositMethod(tradingAssetId int64, method DepositMethod) error {
+	asset, err := a.TradingAssetFromId(tradingAssetId)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range asset.DepositMethods {
+		if m == method {
+			return nil
+		}
+	}
+
+	return ErrDepositMethodNotSupported
+}
@@ -0,0 +1,242 @@
+package blocktrade
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// FEE_RECONCILE_TOLERANCE is the relative difference below which a charged
+// fee is considered equal to the expected one when the quote asset's
+// decimal precision is unknown. Otherwise fees may differ by one unit of
+// precision, as the exchange rounds them.
+const FEE_RECONCILE_TOLERANCE = 1e-6
+
+type FeeRole string
+
+const FeeRole_MAKER FeeRole = "MAKER"
+const FeeRole_TAKER FeeRole = "TAKER"
+
+// FeeDiscrepancy is a fill whose fee differs from the schedule. Difference
+// is positive if more was charged than expected. Amounts are in the quote
+// asset. FeeKey is the schedule key of the expected rate, empty for the
+// reconciler's own MakerFee or TakerFee.
+type FeeDiscrepancy struct {
+	Trade        *TradeResponse
+	QuoteAssetId int64
+	Role         FeeRole
+	FeeKey       string
+	Notional     float64
+	Expected     float64
+	Actual       float64
+	Difference   float64
+}
+
+// FeeRoleSummary sums the fills of one role. EffectiveRate is Fees in
+// percent of Notional. UnknownFills counts the fills without an expected
+// fee, which are left out of Expected.
+type FeeRoleSummary struct {
+	Fills         int
+	UnknownFills  int
+	Notional      float64
+	Fees          float64
+	Expected      float64
+	EffectiveRate float64
+}
+
+// FeeQuoteSummary sums the fills of the pairs quoted in one asset.
+type FeeQuoteSummary struct {
+	QuoteAssetId int64
+	Maker        FeeRoleSummary
+	Taker        FeeRoleSummary
+	Total        FeeRoleSummary
+}
+
+// FeeReport is the result of reconciling the fills of a period. Summaries
+// are per quote asset, sorted by asset id. RoleAware is false when maker
+// and taker fills were both expected to pay the published schedule's rate.
+// UnknownFees lists the fills whose pair has no fee in the schedule, they
+// are not checked for discrepancies.
+type FeeReport struct {
+	From          time.Time
+	To            time.Time
+	RoleAware     bool
+	Quotes        []*FeeQuoteSummary
+	Discrepancies []*FeeDiscrepancy
+	UnknownFees   []*TradeResponse
+}
+
+// FeeReconciler checks the fees charged on fills against the published fee
+// schedule. The schedule has a single rate per pair and does not tell maker
+// and taker apart, so the reconciler only distinguishes the roles when
+// MakerFee and TakerFee are both set to the account's rates.
+type FeeReconciler struct {
+	client *APIClient
+
+	MakerFee *Fee
+	TakerFee *Fee
+}
+
+func NewFeeReconciler(client *APIClient) *FeeReconciler {
+	return &FeeReconciler{
+		client: client,
+	}
+}
+
+// Reconcile compares the fee of every trade dated in [from, to) with the
+// expected fee for its role and notional, rounded to the quote asset's
+// precision. A zero from or to leaves that end of the period open.
+func (r *FeeReconciler) Reconcile(ctx context.Context, trades []*TradeResponse, from, to time.Time) (*FeeReport, error) {
+	schedule, err := r.client.FeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FeeReport{
+		From:          from,
+		To:            to,
+		RoleAware:     r.MakerFee != nil && r.TakerFee != nil,
+		Quotes:        make([]*FeeQuoteSummary, 0),
+		Discrepancies: make([]*FeeDiscrepancy, 0),
+		UnknownFees:   make([]*TradeResponse, 0),
+	}
+
+	quotes := make(map[int64]*FeeQuoteSummary)
+	for _, trade := range trades {
+		date := time.UnixMilli(trade.Date)
+		if (!from.IsZero() && date.Before(from)) || (!to.IsZero() && !date.Before(to)) {
+			continue
+		}
+
+		pair, err := r.client.TradingPairFromId(trade.TradingPairId)
+		if err != nil {
+			return nil, err
+		}
+
+		quoteAsset, err := r.client.TradingAssetFromId(pair.QuoteAssetId)
+		if err != nil {
+			return nil, err
+		}
+
+		notional, err := tradeNotional(trade)
+		if err != nil {
+			return nil, err
+		}
+
+		actual, err := parseOptionalFloat(trade.FeeValue)
+		if err != nil {
+			return nil, err
+		}
+
+		role := FeeRole_TAKER
+		if trade.Make {
+			role = FeeRole_MAKER
+		}
+
+		quote, ok := quotes[pair.QuoteAssetId]
+		if !ok {
+			quote = &FeeQuoteSummary{QuoteAssetId: pair.QuoteAssetId}
+			quotes[pair.QuoteAssetId] = quote
+			report.Quotes = append(report.Quotes, quote)
+		}
+
+		summary := &quote.Taker
+		if role == FeeRole_MAKER {
+			summary = &quote.Maker
+		}
+
+		for _, s := range []*FeeRoleSummary{summary, &quote.Total} {
+			s.Fills++
+			s.Notional += notional
+			s.Fees += actual
+		}
+
+		fee, feeKey, err := r.expectedFee(schedule, trade.TradingPairId, role)
+		if err == ErrUnknownFee {
+			summary.UnknownFills++
+			quote.Total.UnknownFills++
+			report.UnknownFees = append(report.UnknownFees, trade)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		expected, err := fee.Amount(notional)
+		if err != nil {
+			return nil, err
+		}
+
+		tolerance := expected * FEE_RECONCILE_TOLERANCE
+		if precision, ok := quoteAsset.Precision(); ok {
+			unit := math.Pow10(-int(precision))
+			expected = math.Round(expected/unit) * unit
+			tolerance = unit
+		}
+
+		summary.Expected += expected
+		quote.Total.Expected += expected
+
+		// the small epsilon keeps a difference of exactly one unit from
+		// failing on float noise
+		if math.Abs(actual-expected) > tolerance*(1+1e-9) {
+			report.Discrepancies = append(report.Discrepancies, &FeeDiscrepancy{
+				Trade:        trade,
+				QuoteAssetId: pair.QuoteAssetId,
+				Role:         role,
+				FeeKey:       feeKey,
+				Notional:     notional,
+				Expected:     expected,
+				Actual:       actual,
+				Difference:   actual - expected,
+			})
+		}
+	}
+
+	sort.Slice(report.Quotes, func(i, j int) bool {
+		return report.Quotes[i].QuoteAssetId < report.Quotes[j].QuoteAssetId
+	})
+
+	for _, quote := range report.Quotes {
+		for _, s := range []*FeeRoleSummary{&quote.Maker, &quote.Taker, &quote.Total} {
+			if s.Notional != 0 {
+				s.EffectiveRate = s.Fees / s.Notional * 100
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// expectedFee returns the override for role with an empty key, or else the
+// published rate of the pair and its key.
+func (r *FeeReconciler) expectedFee(schedule *FeeSchedule, tradingPairId int64, role FeeRole) (Fee, string, error) {
+	if role == FeeRole_MAKER && r.MakerFee != nil {
+		return *r.MakerFee, "", nil
+	}
+
+	if role == FeeRole_TAKER && r.TakerFee != nil {
+		return *r.TakerFee, "", nil
+	}
+
+	return r.client.guessPairFee(schedule, tradingPairId)
+}
+
+// tradeNotional returns the value of a trade in the quote asset.
+func tradeNotional(trade *TradeResponse) (float64, error) {
+	if trade.TradeValue != "" {
+		return parseOptionalFloat(trade.TradeValue)
+	}
+
+	amount, err := parseOptionalFloat(trade.Amount)
+	if err != nil {
+		return 0, err
+	}
+
+	price, err := parseOptionalFloat(trade.Price)
+	if err != nil {
+		return 0, err
+	}
+
+	return amount * price, nil
+}
@@ -0,0 +1,163 @@
+package blocktrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrUnknownFee = errors.New("no fee published for key")
+
+type WithdrawalMethod string
+
+const WithdrawalMethod_CRYPTO WithdrawalMethod = "CRYPTO"
+const WithdrawalMethod_SEPA WithdrawalMethod = "SEPA"
+const WithdrawalMethod_OUTSIDE_SEPA WithdrawalMethod = "OUTSIDE_SEPA"
+
+// FeeSchedule applies the published fees. Each fee is PercentValue percent
+// of the amount but at least MinFee. The API documentation does not say
+// what the keys of the categories are, so the methods take the key as it
+// appears in the response, e.g. an asset ISO code or a trading pair id. The
+// schedule is a copy, changing it does not affect the cached fees.
+type FeeSchedule struct {
+	*FeeResponse
+
+	// Time is when the fees were fetched.
+	Time time.Time
+}
+
+// TradingFee returns the fee for trading notional with the given key.
+func (s *FeeSchedule) TradingFee(key string, notional float64) (float64, error) {
+	return scheduleFee(s.Trading, key, notional)
+}
+
+// GuessPairTradingFee returns the fee for trading notional on a pair and
+// the key of the rate used. As the key format is undocumented it is a best
+// effort: the pair id, the quote and then the base asset's ISO code are
+// tried, so the rate may belong to an asset rather than the pair. Check the
+// returned key before relying on the amount.
+func (a *APIClient) GuessPairTradingFee(ctx context.Context, tradingPairId int64, notional float64) (float64, string, error) {
+	schedule, err := a.FeeSchedule(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+
+	fee, key, err := a.guessPairFee(schedule, tradingPairId)
+	if err != nil {
+		return 0, "", err
+	}
+
+	amount, err := fee.Amount(notional)
+	return amount, key, err
+}
+
+// WithdrawalFee returns the fee for withdrawing amount of the asset keyed
+// by asset. The fee categories do not name the withdrawal methods, so the
+// mapping is an assumption: crypto withdrawals are taken to be charged the
+// transfer fee plus the miner fee, if one is published.
+func (s *FeeSchedule) WithdrawalFee(method WithdrawalMethod, asset string, amount float64) (float64, error) {
+	switch method {
+	case WithdrawalMethod_CRYPTO:
+		transfer, err := scheduleFee(s.TransferOut, asset, amount)
+		if err != nil {
+			return 0, err
+		}
+
+		miner, err := scheduleFee(s.MinerFee, asset, amount)
+		if err != nil && err != ErrUnknownFee {
+			return 0, err
+		}
+
+		return transfer + miner, nil
+	case WithdrawalMethod_SEPA:
+		return scheduleFee(s.TransferOutSepa, asset, amount)
+	case WithdrawalMethod_OUTSIDE_SEPA:
+		return scheduleFee(s.TransferOutOutsideSepa, asset, amount)
+	}
+
+	return 0, fmt.Errorf("unknown withdrawal method: %v", method)
+}
+
+// DepositFee returns the fee for depositing amount of the asset keyed by
+// asset. The fee categories do not name the deposit methods, so mapping
+// COINIFY to the credit card category is an assumption. Wallet address
+// deposits have no category and return ErrUnknownFee.
+func (s *FeeSchedule) DepositFee(method DepositMethod, asset string, amount float64) (float64, error) {
+	switch method {
+	case DepositMethod_WALLET_ADDRESS:
+		return 0, ErrUnknownFee
+	case DepositMethod_CLEAR_JUNCTION_SEPA:
+		return scheduleFee(s.TransferInSepa, asset, amount)
+	case DepositMethod_COINIFY:
+		return scheduleFee(s.TransferInCreditCard, asset, amount)
+	}
+
+	return 0, fmt.Errorf("unknown deposit method: %v", method)
+}
+
+func scheduleFee(fees map[string]Fee, key string, amount float64) (float64, error) {
+	fee, ok := fees[key]
+	if !ok {
+		return 0, ErrUnknownFee
+	}
+
+	return fee.Amount(amount)
+}
+
+// FeeSchedule returns the cached fee schedule, fetching it if it is missing
+// or older than the metadata TTL.
+func (a *APIClient) FeeSchedule(ctx context.Context) (*FeeSchedule, error) {
+	a.metadata.mtx.RLock()
+	fees, fetched := a.metadata.fees, a.metadata.feesTime
+	stale := fees == nil || a.metadata.stale(fetched)
+	a.metadata.mtx.RUnlock()
+
+	if stale {
+		return a.RefreshFeeSchedule(ctx)
+	}
+
+	return &FeeSchedule{FeeResponse: fees.clone(), Time: fetched}, nil
+}
+
+// RefreshFeeSchedule fetches the fees and replaces the cached schedule.
+func (a *APIClient) RefreshFeeSchedule(ctx context.Context) (*FeeSchedule, error) {
+	err := a.metadata.do(ctx, "fees", func() error {
+		_, err := a.fees(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.metadata.mtx.RLock()
+	defer a.metadata.mtx.RUnlock()
+
+	return &FeeSchedule{FeeResponse: a.metadata.fees.clone(), Time: a.metadata.feesTime}, nil
+}
+
+// guessPairFee returns the trading rate of a pair and its key, trying the
+// keys GuessPairTradingFee describes.
+func (a *APIClient) guessPairFee(schedule *FeeSchedule, tradingPairId int64) (Fee, string, error) {
+	pair, err := a.TradingPairFromId(tradingPairId)
+	if err != nil {
+		return Fee{}, "", err
+	}
+
+	baseAsset, err := a.TradingAssetFromId(pair.BaseAssetId)
+	if err != nil {
+		return Fee{}, "", err
+	}
+
+	quoteAsset, err := a.TradingAssetFromId(pair.QuoteAssetId)
+	if err != nil {
+		return Fee{}, "", err
+	}
+
+	fee, key, ok := schedule.tradingFee(fmt.Sprint(pair.Id), quoteAsset.IsoCode, baseAsset.IsoCode)
+	if !ok {
+		return Fee{}, "", ErrUnknownFee
+	}
+
+	return fee, key, nil
+}
@@ -1,6 +1,11 @@
 package blocktrade
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+)
 
 const FEES_ENDPOINT = "/fees"
 
@@ -21,12 +26,98 @@ type FeeResponse struct {
 }
 
 func (a *APIClient) Fees() (*FeeResponse, error) {
-	b, err := a.requestGET(FEES_ENDPOINT)
+	return a.fees(context.Background())
+}
+
+func (a *APIClient) fees(ctx context.Context) (*FeeResponse, error) {
+	b, err := a.requestGETContext(ctx, FEES_ENDPOINT)
 	if err != nil {
 		return nil, err
 	}
 
 	resp := new(FeeResponse)
 	err = json.Unmarshal(b, &resp)
-	return resp, err
+	if err != nil {
+		return nil, err
+	}
+
+	a.metadata.mtx.Lock()
+	a.metadata.fees = resp.clone()
+	a.metadata.feesTime = time.Now()
+	a.metadata.mtx.Unlock()
+
+	return resp, nil
+}
+
+// Amount returns the fee for notional: PercentValue percent of notional but
+// at least MinFee.
+func (f Fee) Amount(notional float64) (float64, error) {
+	percent, err := parseOptionalFloat(f.PercentValue)
+	if err != nil {
+		return 0, err
+	}
+
+	minFee, err := parseOptionalFloat(f.MinFee)
+	if err != nil {
+		return 0, err
+	}
+
+	fee := notional * percent / 100
+	if fee < minFee {
+		fee = minFee
+	}
+
+	return fee, nil
+}
+
+// clone copies the fee maps, so that the cached fees cannot be changed
+// through a returned response.
+func (f *FeeResponse) clone() *FeeResponse {
+	if f == nil {
+		return nil
+	}
+
+	return &FeeResponse{
+		Trading:                cloneFees(f.Trading),
+		TransferInCreditCard:   cloneFees(f.TransferInCreditCard),
+		TransferInSepa:         cloneFees(f.TransferInSepa),
+		TransferInOutsideSepa:  cloneFees(f.TransferInOutsideSepa),
+		TransferOutSepa:        cloneFees(f.TransferOutSepa),
+		TransferOutOutsideSepa: cloneFees(f.TransferOutOutsideSepa),
+		MinerFee:               cloneFees(f.MinerFee),
+		TransferOut:            cloneFees(f.TransferOut),
+	}
+}
+
+func cloneFees(fees map[string]Fee) map[string]Fee {
+	if fees == nil {
+		return nil
+	}
+
+	clone := make(map[string]Fee, len(fees))
+	for key, fee := range fees {
+		clone[key] = fee
+	}
+
+	return clone
+}
+
+// tradingFee returns the trading fee for the first of keys present in the
+// schedule and that key.
+func (f *FeeResponse) tradingFee(keys ...string) (Fee, string, bool) {
+	for _, key := range keys {
+		if fee, ok := f.Trading[key]; ok {
+			return fee, key, true
+		}
+	}
+
+	return Fee{}, "", false
+}
+
+func parseOptionalFloat(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(s, 64)
 }
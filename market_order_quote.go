@@ -0,0 +1,219 @@
+package blocktrade
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInsufficientBalance = errors.New("insufficient balance")
+var ErrBelowMinimalOrderValue = errors.New("order value below minimal order value")
+var ErrAboveMaximumOrderValue = errors.New("order value above maximum order value")
+
+// MarketOrderQuote is the expected outcome of a market order. Amount is in
+// the base asset, all values and the fee are in the quote asset.
+type MarketOrderQuote struct {
+	TradingPairId int64
+	Direction     Direction
+	Amount        float64
+	AveragePrice  float64
+	WorstPrice    float64
+	SlippageBps   float64
+
+	// Value is Amount at AveragePrice. Total is what is spent for a buy,
+	// Value plus Fee, or received for a sell, Value minus Fee.
+	Value   float64
+	Fee     float64
+	FeeRate Fee
+	Total   float64
+
+	// TakerFee is true if FeeRate is the account's taker rate set with
+	// SetTakerFee. Otherwise it is the published rate found under FeeKey,
+	// see GuessPairTradingFee, which does not tell maker and taker apart.
+	TakerFee bool
+	FeeKey   string
+
+	// AvailableAmount is the available balance of the asset being spent,
+	// the quote asset for buys and the base asset for sells.
+	AvailableAmount float64
+
+	// Problems lists why the order would likely be rejected.
+	Problems []error
+}
+
+// Err returns the first problem with the quote, if any.
+func (q *MarketOrderQuote) Err() error {
+	if len(q.Problems) == 0 {
+		return nil
+	}
+
+	return q.Problems[0]
+}
+
+// SetTakerFee sets the account's taker fee used by EstimateMarketOrder
+// instead of the published rate of each pair. Passing nil removes it.
+func (a *APIClient) SetTakerFee(fee *Fee) {
+	a.metadata.mtx.Lock()
+	a.metadata.takerFee = fee
+	a.metadata.mtx.Unlock()
+}
+
+// EstimateMarketOrder estimates a market order of amount base asset against
+// the current order book, including the taker fee, and checks it against the
+// default portfolio's balance and the quote asset's order value limits.
+func (a *APIClient) EstimateMarketOrder(ctx context.Context, tradingPairId int64, direction Direction, amount float64) (*MarketOrderQuote, error) {
+	portfolioId, err := a.GetPortfolioId()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.EstimateMarketOrderInPortfolio(ctx, portfolioId, tradingPairId, direction, amount)
+}
+
+// EstimateMarketOrderInPortfolio is like EstimateMarketOrder but checks the
+// balance of the given portfolio.
+func (a *APIClient) EstimateMarketOrderInPortfolio(ctx context.Context, portfolioId int64, tradingPairId int64, direction Direction, amount float64) (*MarketOrderQuote, error) {
+	pair, err := a.TradingPairFromId(tradingPairId)
+	if err != nil {
+		return nil, err
+	}
+
+	quoteAsset, err := a.TradingAssetFromId(pair.QuoteAssetId)
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := a.getOrderBook(ctx, tradingPairId)
+	if err != nil {
+		return nil, err
+	}
+
+	avgPrice, err := book.AverageFillPrice(direction, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	levels, err := book.takerLevels(direction)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &MarketOrderQuote{
+		TradingPairId: tradingPairId,
+		Direction:     direction,
+		Amount:        amount,
+		AveragePrice:  avgPrice,
+		WorstPrice:    worstFillPrice(levels, amount),
+		Value:         avgPrice * amount,
+	}
+
+	quote.SlippageBps, err = book.SlippageBps(direction, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, feeKey, err := a.takerFee(ctx, tradingPairId)
+	unknownFee := err == ErrUnknownFee
+	if err != nil && !unknownFee {
+		return nil, err
+	}
+
+	if !unknownFee {
+		quote.FeeRate = fee
+		quote.TakerFee = feeKey == ""
+		quote.FeeKey = feeKey
+		quote.Fee, err = fee.Amount(quote.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	spentAssetId := pair.QuoteAssetId
+	quote.Total = quote.Value + quote.Fee
+	if direction == Direction_SELL {
+		spentAssetId = pair.BaseAssetId
+		quote.Total = quote.Value - quote.Fee
+	}
+
+	quote.AvailableAmount, err = a.availableAmount(ctx, portfolioId, spentAssetId)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := quote.Total
+	if direction == Direction_SELL {
+		spent = amount
+	}
+	if spent > quote.AvailableAmount {
+		quote.Problems = append(quote.Problems, ErrInsufficientBalance)
+	}
+
+	minValue, err := parseOptionalFloat(quoteAsset.MinimalOrderValue)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Value < minValue {
+		quote.Problems = append(quote.Problems, ErrBelowMinimalOrderValue)
+	}
+
+	maxValue, err := parseOptionalFloat(quoteAsset.MaximumOrderValue)
+	if err != nil {
+		return nil, err
+	}
+	if maxValue > 0 && quote.Value > maxValue {
+		quote.Problems = append(quote.Problems, ErrAboveMaximumOrderValue)
+	}
+
+	if unknownFee {
+		quote.Problems = append(quote.Problems, ErrUnknownFee)
+	}
+
+	return quote, nil
+}
+
+// takerFee returns the rate set with SetTakerFee and an empty key, or else
+// the published rate of the pair and its key.
+func (a *APIClient) takerFee(ctx context.Context, tradingPairId int64) (Fee, string, error) {
+	a.metadata.mtx.RLock()
+	takerFee := a.metadata.takerFee
+	a.metadata.mtx.RUnlock()
+
+	if takerFee != nil {
+		return *takerFee, "", nil
+	}
+
+	schedule, err := a.FeeSchedule(ctx)
+	if err != nil {
+		return Fee{}, "", err
+	}
+
+	return a.guessPairFee(schedule, tradingPairId)
+}
+
+func worstFillPrice(levels []OrderBookLevel, amount float64) float64 {
+	remaining := amount
+	for _, level := range levels {
+		remaining -= level.Amount
+		if remaining <= 0 {
+			return level.Price
+		}
+	}
+
+	return 0
+}
+
+// availableAmount returns the available balance of the asset in the
+// portfolio.
+func (a *APIClient) availableAmount(ctx context.Context, portfolioId int64, tradingAssetId int64) (float64, error) {
+	portfolio, err := a.GetPortfolio(ctx, portfolioId)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, asset := range portfolio.Assets {
+		if asset.TradingAssetId == tradingAssetId {
+			return parseOptionalFloat(asset.AvailableAmount)
+		}
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,96 @@
+package blocktrade
+
+import "context"
+
+// MarketSummary combines a trading pair's ticker with its metadata. Volume
+// is in the base asset, QuoteVolume is Volume valued at LastPrice.
+type MarketSummary struct {
+	TradingPairId int64
+	Symbol        string
+	BaseAsset     *TradingAsset
+	QuoteAsset    *TradingAsset
+
+	LastPrice   float64
+	BidPrice    float64
+	AskPrice    float64
+	High        float64
+	Low         float64
+	Volume      float64
+	QuoteVolume float64
+	Spread      float64
+	SpreadBps   float64
+}
+
+// MarketSummaries returns a summary of the given trading pairs, or of all
+// trading pairs if none are given.
+func (a *APIClient) MarketSummaries(ctx context.Context, tradingPairIds ...int64) ([]*MarketSummary, error) {
+	tickers, err := a.GetTickers(ctx, tradingPairIds...)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*MarketSummary, 0, len(tickers))
+	for _, ticker := range tickers {
+		summary, err := a.marketSummary(ticker)
+		if err != nil {
+			return nil, err
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+func (a *APIClient) marketSummary(ticker *TickerResponse) (*MarketSummary, error) {
+	pair, err := a.TradingPairFromId(ticker.TradingPairId)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &MarketSummary{
+		TradingPairId: pair.Id,
+	}
+
+	summary.BaseAsset, err = a.TradingAssetFromId(pair.BaseAssetId)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.QuoteAsset, err = a.TradingAssetFromId(pair.QuoteAssetId)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Symbol, err = a.pairSymbol(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []struct {
+		value *float64
+		s     string
+	}{
+		{&summary.LastPrice, ticker.Data.LastPrice},
+		{&summary.BidPrice, ticker.Data.BidPrice},
+		{&summary.AskPrice, ticker.Data.AskPrice},
+		{&summary.High, ticker.Data.High},
+		{&summary.Low, ticker.Data.Low},
+		{&summary.Volume, ticker.Data.Volume},
+	}
+
+	for _, field := range fields {
+		*field.value, err = parseOptionalFloat(field.s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	summary.QuoteVolume = summary.Volume * summary.LastPrice
+	if summary.BidPrice > 0 && summary.AskPrice > 0 {
+		summary.Spread = summary.AskPrice - summary.BidPrice
+		summary.SpreadBps = summary.Spread / ((summary.AskPrice + summary.BidPrice) / 2) * 1e4
+	}
+
+	return summary, nil
+}
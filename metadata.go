@@ -0,0 +1,425 @@
+package blocktrade
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+const METADATA_TTL = 15 * time.Minute
+const METADATA_MISS_TTL = time.Minute
+
+// METADATA_RETRY_BACKOFF is how long a failed background refresh waits
+// before it is tried again. It doubles with every failure up to
+// METADATA_MAX_RETRY_BACKOFF.
+const METADATA_RETRY_BACKOFF = 5 * time.Second
+const METADATA_MAX_RETRY_BACKOFF = 5 * time.Minute
+
+// MetadataChange describes an asset or pair that was added, changed or
+// removed by a refresh. Old is nil for added and New is nil for removed
+// entries. Either the asset or the pair fields are set.
+type MetadataChange struct {
+	OldAsset *TradingAsset
+	NewAsset *TradingAsset
+	OldPair  *TradingPair
+	NewPair  *TradingPair
+}
+
+type MetadataChangeHandlerFunc func(change *MetadataChange)
+
+type metadataFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// metadataCache holds trading assets and pairs. Lookups of entries older
+// than ttl return the cached value and refresh in the background. Failed
+// lookups are remembered for missTTL, or until the next refresh once the
+// cache was preloaded, so that they do not refetch everything again.
+type metadataCache struct {
+	mtx        sync.RWMutex
+	assets     map[int64]*TradingAsset
+	pairs      map[int64]*TradingPair
+	assetsTime time.Time
+	pairsTime  time.Time
+	fees       *FeeResponse
+	feesTime   time.Time
+	takerFee   *Fee
+	ttl        time.Duration
+	onChange   MetadataChangeHandlerFunc
+
+	misses    map[string]metadataMiss
+	missTTL   time.Duration
+	preloaded bool
+
+	flightMtx sync.Mutex
+	flights   map[string]*metadataFlight
+	retries   map[string]*metadataRetry
+}
+
+type metadataMiss struct {
+	time time.Time
+	err  error
+}
+
+// metadataRetry tracks the background refresh of one key.
+type metadataRetry struct {
+	running bool
+	next    time.Time
+	backoff time.Duration
+}
+
+func newMetadataCache() *metadataCache {
+	return &metadataCache{
+		assets:  make(map[int64]*TradingAsset),
+		pairs:   make(map[int64]*TradingPair),
+		ttl:     METADATA_TTL,
+		misses:  make(map[string]metadataMiss),
+		missTTL: METADATA_MISS_TTL,
+		flights: make(map[string]*metadataFlight),
+		retries: make(map[string]*metadataRetry),
+	}
+}
+
+// SetMetadataTTL sets after how long cached assets and pairs are refreshed
+// in the background. A zero ttl disables refreshing.
+func (a *APIClient) SetMetadataTTL(ttl time.Duration) {
+	a.metadata.mtx.Lock()
+	a.metadata.ttl = ttl
+	a.metadata.mtx.Unlock()
+}
+
+// SetMetadataMissTTL sets for how long a failed asset or pair lookup is
+// answered from memory before the metadata is fetched again.
+func (a *APIClient) SetMetadataMissTTL(ttl time.Duration) {
+	a.metadata.mtx.Lock()
+	a.metadata.missTTL = ttl
+	a.metadata.mtx.Unlock()
+}
+
+// OnMetadataChange registers f to be called for every asset or pair that
+// changes on a refresh after the initial load. Passing nil removes the hook.
+func (a *APIClient) OnMetadataChange(f MetadataChangeHandlerFunc) {
+	a.metadata.mtx.Lock()
+	a.metadata.onChange = f
+	a.metadata.mtx.Unlock()
+}
+
+// RefreshMetadata reloads all trading assets and pairs.
+func (a *APIClient) RefreshMetadata(ctx context.Context) error {
+	err := a.refreshAssets(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.refreshPairs(ctx)
+}
+
+// PreloadMetadata loads all trading assets and pairs. Afterwards lookups
+// are served from memory only, unknown assets and pairs fail without a
+// request and the cache is kept current by the TTL refresh.
+func (a *APIClient) PreloadMetadata(ctx context.Context) error {
+	err := a.RefreshMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.metadata.mtx.Lock()
+	a.metadata.preloaded = true
+	a.metadata.mtx.Unlock()
+
+	return nil
+}
+
+// InvalidateMetadata drops all cached trading assets, pairs and fees.
+func (a *APIClient) InvalidateMetadata() {
+	a.metadata.mtx.Lock()
+	defer a.metadata.mtx.Unlock()
+
+	a.metadata.assets = make(map[int64]*TradingAsset)
+	a.metadata.pairs = make(map[int64]*TradingPair)
+	a.metadata.assetsTime = time.Time{}
+	a.metadata.pairsTime = time.Time{}
+	a.metadata.fees = nil
+	a.metadata.feesTime = time.Time{}
+	a.metadata.misses = make(map[string]metadataMiss)
+	a.metadata.preloaded = false
+}
+
+// knownMiss reports whether a lookup for key should fail without fetching,
+// and the error of the failed fetch if that is why it missed.
+func (c *metadataCache) knownMiss(key string) (bool, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	if c.preloaded {
+		return true, nil
+	}
+
+	miss, ok := c.misses[key]
+	if !ok || time.Since(miss.time) >= c.missTTL {
+		return false, nil
+	}
+
+	return true, miss.err
+}
+
+// recordMiss remembers that a lookup for key failed, err is set if fetching
+// the metadata failed.
+func (c *metadataCache) recordMiss(key string, err error) {
+	c.mtx.Lock()
+	c.misses[key] = metadataMiss{time: time.Now(), err: err}
+	c.mtx.Unlock()
+}
+
+// cachedAssetFromId looks an asset up by its id without scanning the cache.
+func (a *APIClient) cachedAssetFromId(id int64) (*TradingAsset, bool) {
+	a.metadata.mtx.RLock()
+	defer a.metadata.mtx.RUnlock()
+
+	a.refreshStaleAssets()
+
+	asset, ok := a.metadata.assets[id]
+	return asset, ok
+}
+
+func (a *APIClient) cachedAsset(match func(asset *TradingAsset) bool) (*TradingAsset, bool) {
+	a.metadata.mtx.RLock()
+	defer a.metadata.mtx.RUnlock()
+
+	a.refreshStaleAssets()
+
+	for _, asset := range a.metadata.assets {
+		if match(asset) {
+			return asset, true
+		}
+	}
+
+	return nil, false
+}
+
+// cachedPairFromId looks a pair up by its id without scanning the cache.
+func (a *APIClient) cachedPairFromId(id int64) (*TradingPair, bool) {
+	a.metadata.mtx.RLock()
+	defer a.metadata.mtx.RUnlock()
+
+	a.refreshStalePairs()
+
+	pair, ok := a.metadata.pairs[id]
+	return pair, ok
+}
+
+func (a *APIClient) cachedPair(match func(pair *TradingPair) bool) (*TradingPair, bool) {
+	a.metadata.mtx.RLock()
+	defer a.metadata.mtx.RUnlock()
+
+	a.refreshStalePairs()
+
+	for _, pair := range a.metadata.pairs {
+		if match(pair) {
+			return pair, true
+		}
+	}
+
+	return nil, false
+}
+
+// refreshStaleAssets and refreshStalePairs start a background refresh if
+// the cached entries are too old. They are called with mtx read locked.
+func (a *APIClient) refreshStaleAssets() {
+	if a.metadata.stale(a.metadata.assetsTime) {
+		a.metadata.refreshInBackground("assets", a.refreshAssets)
+	}
+}
+
+func (a *APIClient) refreshStalePairs() {
+	if a.metadata.stale(a.metadata.pairsTime) {
+		a.metadata.refreshInBackground("pairs", a.refreshPairs)
+	}
+}
+
+// cachedPairs returns all cached pairs sorted by id, loading them first if
+// the cache is empty.
+func (a *APIClient) cachedPairs(ctx context.Context) ([]*TradingPair, error) {
+	a.metadata.mtx.RLock()
+	empty := a.metadata.pairsTime.IsZero()
+	a.metadata.mtx.RUnlock()
+
+	if empty {
+		err := a.refreshPairs(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	a.metadata.mtx.RLock()
+	defer a.metadata.mtx.RUnlock()
+
+	pairs := make([]*TradingPair, 0, len(a.metadata.pairs))
+	for _, pair := range a.metadata.pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Id < pairs[j].Id
+	})
+
+	return pairs, nil
+}
+
+func (c *metadataCache) stale(fetched time.Time) bool {
+	return c.ttl > 0 && !fetched.IsZero() && time.Since(fetched) > c.ttl
+}
+
+func (a *APIClient) refreshAssets(ctx context.Context) error {
+	return a.metadata.do(ctx, "assets", func() error {
+		assets, err := a.tradingAssets(ctx)
+		if err != nil {
+			return err
+		}
+
+		newAssets := make(map[int64]*TradingAsset)
+		for _, asset := range assets {
+			newAssets[asset.Id] = asset
+		}
+
+		a.metadata.mtx.Lock()
+		oldAssets := a.metadata.assets
+		initial := a.metadata.assetsTime.IsZero()
+		a.metadata.assets = newAssets
+		a.metadata.assetsTime = time.Now()
+		a.metadata.misses = make(map[string]metadataMiss)
+		onChange := a.metadata.onChange
+		a.metadata.mtx.Unlock()
+
+		if onChange == nil || initial {
+			return nil
+		}
+
+		for id, newAsset := range newAssets {
+			if oldAsset, ok := oldAssets[id]; !ok || !reflect.DeepEqual(oldAsset, newAsset) {
+				onChange(&MetadataChange{OldAsset: oldAsset, NewAsset: newAsset})
+			}
+		}
+		for id, oldAsset := range oldAssets {
+			if _, ok := newAssets[id]; !ok {
+				onChange(&MetadataChange{OldAsset: oldAsset})
+			}
+		}
+
+		return nil
+	})
+}
+
+func (a *APIClient) refreshPairs(ctx context.Context) error {
+	return a.metadata.do(ctx, "pairs", func() error {
+		pairs, err := a.tradingPairs(ctx)
+		if err != nil {
+			return err
+		}
+
+		newPairs := make(map[int64]*TradingPair)
+		for _, pair := range pairs {
+			newPairs[pair.Id] = pair
+		}
+
+		a.metadata.mtx.Lock()
+		oldPairs := a.metadata.pairs
+		initial := a.metadata.pairsTime.IsZero()
+		a.metadata.pairs = newPairs
+		a.metadata.pairsTime = time.Now()
+		a.metadata.misses = make(map[string]metadataMiss)
+		onChange := a.metadata.onChange
+		a.metadata.mtx.Unlock()
+
+		if onChange == nil || initial {
+			return nil
+		}
+
+		for id, newPair := range newPairs {
+			if oldPair, ok := oldPairs[id]; !ok || !reflect.DeepEqual(oldPair, newPair) {
+				onChange(&MetadataChange{OldPair: oldPair, NewPair: newPair})
+			}
+		}
+		for id, oldPair := range oldPairs {
+			if _, ok := newPairs[id]; !ok {
+				onChange(&MetadataChange{OldPair: oldPair})
+			}
+		}
+
+		return nil
+	})
+}
+
+// do runs f unless a call for key is already in flight, in which case it
+// waits for that call's result instead.
+func (c *metadataCache) do(ctx context.Context, key string, f func() error) error {
+	c.flightMtx.Lock()
+	flight, ok := c.flights[key]
+	if !ok {
+		flight = &metadataFlight{done: make(chan struct{})}
+		c.flights[key] = flight
+		c.flightMtx.Unlock()
+
+		flight.err = f()
+
+		c.flightMtx.Lock()
+		delete(c.flights, key)
+		c.flightMtx.Unlock()
+		close(flight.done)
+
+		return flight.err
+	}
+	c.flightMtx.Unlock()
+
+	select {
+	case <-flight.done:
+		return flight.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refreshInBackground starts refresh unless one for key is running or the
+// last one failed less than its backoff ago. The check and the start happen
+// under one lock, so concurrent lookups start at most one refresh.
+func (c *metadataCache) refreshInBackground(key string, refresh func(ctx context.Context) error) {
+	c.flightMtx.Lock()
+	defer c.flightMtx.Unlock()
+
+	retry, ok := c.retries[key]
+	if !ok {
+		retry = &metadataRetry{}
+		c.retries[key] = retry
+	}
+
+	if _, ok := c.flights[key]; ok || retry.running || time.Now().Before(retry.next) {
+		return
+	}
+	retry.running = true
+
+	go func() {
+		err := refresh(context.Background())
+
+		c.flightMtx.Lock()
+		defer c.flightMtx.Unlock()
+
+		retry.running = false
+		if err == nil {
+			retry.backoff = 0
+			retry.next = time.Time{}
+			return
+		}
+
+		retry.backoff *= 2
+		if retry.backoff < METADATA_RETRY_BACKOFF {
+			retry.backoff = METADATA_RETRY_BACKOFF
+		}
+		if retry.backoff > METADATA_MAX_RETRY_BACKOFF {
+			retry.backoff = METADATA_MAX_RETRY_BACKOFF
+		}
+		retry.next = time.Now().Add(retry.backoff)
+	}()
+}
@@ -0,0 +1,198 @@
+package blocktrade
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+const METADATA_SNAPSHOT_VERSION = 1
+
+var ErrIncompleteMetadataSnapshot = errors.New("metadata snapshot is missing assets, pairs or its creation time")
+
+// MetadataSnapshot is a serialisable copy of the client's trading assets,
+// pairs and fees. CreatedAt is when the oldest of them was fetched.
+type MetadataSnapshot struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Assets    []*TradingAsset `json:"assets"`
+	Pairs     []*TradingPair  `json:"pairs"`
+	Fees      *FeeResponse    `json:"fees,omitempty"`
+}
+
+// Age returns how long ago the snapshot's data was fetched.
+func (s *MetadataSnapshot) Age() time.Duration {
+	return time.Since(s.CreatedAt)
+}
+
+// Validate returns ErrIncompleteMetadataSnapshot unless the snapshot has
+// assets, pairs and a creation time.
+func (s *MetadataSnapshot) Validate() error {
+	if len(s.Assets) == 0 || len(s.Pairs) == 0 || s.CreatedAt.IsZero() {
+		return ErrIncompleteMetadataSnapshot
+	}
+
+	return nil
+}
+
+// MetadataSnapshot returns the cached metadata. Fees are only included if
+// they were fetched with Fees before. Parts that were never fetched do not
+// count towards CreatedAt, use Validate to check the snapshot is complete.
+func (a *APIClient) MetadataSnapshot() *MetadataSnapshot {
+	a.metadata.mtx.RLock()
+	defer a.metadata.mtx.RUnlock()
+
+	snapshot := &MetadataSnapshot{
+		Version: METADATA_SNAPSHOT_VERSION,
+		Assets:  make([]*TradingAsset, 0, len(a.metadata.assets)),
+		Pairs:   make([]*TradingPair, 0, len(a.metadata.pairs)),
+		Fees:    a.metadata.fees.clone(),
+	}
+
+	for _, fetched := range []time.Time{a.metadata.assetsTime, a.metadata.pairsTime, a.metadata.feesTime} {
+		if !fetched.IsZero() && (snapshot.CreatedAt.IsZero() || fetched.Before(snapshot.CreatedAt)) {
+			snapshot.CreatedAt = fetched
+		}
+	}
+
+	for _, asset := range a.metadata.assets {
+		snapshot.Assets = append(snapshot.Assets, asset)
+	}
+
+	for _, pair := range a.metadata.pairs {
+		snapshot.Pairs = append(snapshot.Pairs, pair)
+	}
+
+	return snapshot
+}
+
+// SaveMetadata writes the cached metadata to a JSON file. It fails with
+// ErrIncompleteMetadataSnapshot unless assets and pairs were loaded.
+func (a *APIClient) SaveMetadata(path string) error {
+	snapshot := a.MetadataSnapshot()
+	err := snapshot.Validate()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// LoadMetadata reads a file written by SaveMetadata into the cache.
+func (a *APIClient) LoadMetadata(path string) (*MetadataSnapshot, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := new(MetadataSnapshot)
+	err = json.Unmarshal(b, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.LoadMetadataSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// LoadMetadataSnapshot replaces the cached metadata with snapshot. The
+// entries keep the snapshot's age, so the TTL refresh updates them on first
+// use once they are stale. Incomplete snapshots are rejected.
+func (a *APIClient) LoadMetadataSnapshot(snapshot *MetadataSnapshot) error {
+	if snapshot.Version != METADATA_SNAPSHOT_VERSION {
+		return fmt.Errorf("unsupported metadata snapshot version %d", snapshot.Version)
+	}
+
+	err := snapshot.Validate()
+	if err != nil {
+		return err
+	}
+
+	assets := make(map[int64]*TradingAsset)
+	for _, asset := range snapshot.Assets {
+		assets[asset.Id] = asset
+	}
+
+	pairs := make(map[int64]*TradingPair)
+	for _, pair := range snapshot.Pairs {
+		pairs[pair.Id] = pair
+	}
+
+	a.metadata.mtx.Lock()
+	defer a.metadata.mtx.Unlock()
+
+	a.metadata.assets = assets
+	a.metadata.pairs = pairs
+	a.metadata.assetsTime = snapshot.CreatedAt
+	a.metadata.pairsTime = snapshot.CreatedAt
+	a.metadata.misses = make(map[string]metadataMiss)
+
+	if snapshot.Fees != nil {
+		a.metadata.fees = snapshot.Fees.clone()
+		a.metadata.feesTime = snapshot.CreatedAt
+	}
+
+	return nil
+}
+
+// RefreshMetadataInBackground retries RefreshMetadata every retryInterval
+// until it succeeds, e.g. once the network is reachable after loading a
+// snapshot, or ctx is done. Cached fees, e.g. from a snapshot, are
+// refreshed as well. A non-positive retryInterval uses
+// METADATA_RETRY_BACKOFF. The returned channel receives the final result.
+func (a *APIClient) RefreshMetadataInBackground(ctx context.Context, retryInterval time.Duration) <-chan error {
+	result := make(chan error, 1)
+
+	if retryInterval <= 0 {
+		retryInterval = METADATA_RETRY_BACKOFF
+	}
+
+	a.metadata.mtx.RLock()
+	refreshFees := a.metadata.fees != nil
+	a.metadata.mtx.RUnlock()
+
+	go func() {
+		ticker := time.NewTicker(retryInterval)
+		defer ticker.Stop()
+
+		metadataDone := false
+		for {
+			var err error
+			if !metadataDone {
+				err = a.RefreshMetadata(ctx)
+				metadataDone = err == nil
+			}
+
+			if err == nil && refreshFees {
+				_, err = a.RefreshFeeSchedule(ctx)
+				refreshFees = err != nil
+			}
+
+			if err == nil {
+				result <- nil
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				result <- ctx.Err()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return result
+}
@@ -1,31 +1,212 @@
 package blocktrade
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"time"
 )
 
 const ORDER_BOOK_ENDPOINT = "/order_book"
 
+var ErrOrderBookCrossed = errors.New("order book crossed")
+
 type OrderBookEntry struct {
 	Amount string `json:"amount"`
 	Price  string `json:"price"`
 	Value  string `json:"value"`
 }
 
+type OrderBookLevel struct {
+	Price  float64
+	Amount float64
+}
+
 type OrderBookResponse struct {
 	Asks []OrderBookEntry `json:"asks"`
 	Bids []OrderBookEntry `json:"bids"`
+
+	// AskLevels and BidLevels are the parsed levels, set by Normalize.
+	AskLevels []OrderBookLevel `json:"-"`
+	BidLevels []OrderBookLevel `json:"-"`
+
+	// Time is when the snapshot was taken.
+	Time time.Time `json:"-"`
 }
 
 func (a *APIClient) GetOrderBook(tradingPairId int64) (*OrderBookResponse, error) {
+	return a.getOrderBook(context.Background(), tradingPairId)
+}
+
+func (a *APIClient) getOrderBook(ctx context.Context, tradingPairId int64) (*OrderBookResponse, error) {
 	url := fmt.Sprintf("%v/%d", ORDER_BOOK_ENDPOINT, tradingPairId)
-	b, err := a.requestPublicGET(url)
+	b, err := a.requestPublicGETContext(ctx, url)
 	if err != nil {
 		return nil, err
 	}
 
 	resp := new(OrderBookResponse)
 	err = json.Unmarshal(b, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Time = time.Now()
+	err = resp.Normalize()
 	return resp, err
 }
+
+// Normalize sorts the book, bids descending and asks ascending, merges
+// levels with the same price, drops empty levels and fills AskLevels and
+// BidLevels.
+func (r *OrderBookResponse) Normalize() error {
+	asks, err := parseLevels(r.Asks, false)
+	if err != nil {
+		return err
+	}
+
+	bids, err := parseLevels(r.Bids, true)
+	if err != nil {
+		return err
+	}
+
+	r.Asks = mergeEntries(r.Asks, asks)
+	r.Bids = mergeEntries(r.Bids, bids)
+	r.AskLevels = asks
+	r.BidLevels = bids
+
+	return nil
+}
+
+// Crossed reports whether the best bid is at or above the best ask.
+func (r *OrderBookResponse) Crossed() bool {
+	bid, err := r.BestBidPrice()
+	if err != nil {
+		return false
+	}
+
+	ask, err := r.BestAskPrice()
+	if err != nil {
+		return false
+	}
+
+	return bid >= ask
+}
+
+// Validate returns ErrOrderBookCrossed for a crossed book.
+func (r *OrderBookResponse) Validate() error {
+	if r.Crossed() {
+		return ErrOrderBookCrossed
+	}
+
+	return nil
+}
+
+// Depth returns a copy of the book truncated to the best n levels per side.
+// A non-positive n copies the whole book.
+func (r *OrderBookResponse) Depth(n int) *OrderBookResponse {
+	return &OrderBookResponse{
+		Asks:      truncateEntries(r.Asks, n),
+		Bids:      truncateEntries(r.Bids, n),
+		AskLevels: truncateLevels(r.AskLevels, n),
+		BidLevels: truncateLevels(r.BidLevels, n),
+		Time:      r.Time,
+	}
+}
+
+func truncateEntries(entries []OrderBookEntry, n int) []OrderBookEntry {
+	if n > 0 && n < len(entries) {
+		entries = entries[:n]
+	}
+
+	return append([]OrderBookEntry(nil), entries...)
+}
+
+func truncateLevels(levels []OrderBookLevel, n int) []OrderBookLevel {
+	if levels == nil {
+		return nil
+	}
+
+	if n > 0 && n < len(levels) {
+		levels = levels[:n]
+	}
+
+	return append([]OrderBookLevel{}, levels...)
+}
+
+// parseLevels parses, merges and sorts entries. Empty levels are dropped.
+func parseLevels(entries []OrderBookEntry, descending bool) ([]OrderBookLevel, error) {
+	levels := make([]OrderBookLevel, 0, len(entries))
+	index := make(map[float64]int)
+	for _, entry := range entries {
+		price, err := strconv.ParseFloat(entry.Price, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order book price %q: %w", entry.Price, err)
+		}
+
+		amount, err := strconv.ParseFloat(entry.Amount, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order book amount %q: %w", entry.Amount, err)
+		}
+
+		if price <= 0 || amount < 0 {
+			return nil, fmt.Errorf("invalid order book level: price %v amount %v", entry.Price, entry.Amount)
+		}
+
+		if amount == 0 {
+			continue
+		}
+
+		if i, ok := index[price]; ok {
+			levels[i].Amount += amount
+			continue
+		}
+
+		index[price] = len(levels)
+		levels = append(levels, OrderBookLevel{Price: price, Amount: amount})
+	}
+
+	sort.Slice(levels, func(i, j int) bool {
+		if descending {
+			return levels[i].Price > levels[j].Price
+		}
+		return levels[i].Price < levels[j].Price
+	})
+
+	return levels, nil
+}
+
+// mergeEntries rebuilds entries in the order of levels, keeping the
+// original strings for levels that were not merged.
+func mergeEntries(entries []OrderBookEntry, levels []OrderBookLevel) []OrderBookEntry {
+	byPrice := make(map[float64]OrderBookEntry)
+	count := make(map[float64]int)
+	for _, entry := range entries {
+		price, _ := strconv.ParseFloat(entry.Price, 64)
+		byPrice[price] = entry
+		count[price]++
+	}
+
+	merged := make([]OrderBookEntry, 0, len(levels))
+	for _, level := range levels {
+		entry := byPrice[level.Price]
+		if count[level.Price] > 1 {
+			entry = OrderBookEntry{
+				Amount: formatFloat(level.Amount),
+				Price:  entry.Price,
+				Value:  formatFloat(level.Amount * level.Price),
+			}
+		}
+
+		merged = append(merged, entry)
+	}
+
+	return merged
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
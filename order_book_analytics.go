@@ -0,0 +1,188 @@
+package blocktrade
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrOrderBookEmpty = errors.New("order book empty")
+var ErrInsufficientLiquidity = errors.New("insufficient liquidity in order book")
+
+// takerLevels returns the levels an order in direction fills against, best
+// price first: asks for buys and bids for sells.
+func (r *OrderBookResponse) takerLevels(direction Direction) ([]OrderBookLevel, error) {
+	switch direction {
+	case Direction_BUY:
+		if r.AskLevels != nil {
+			return r.AskLevels, nil
+		}
+		return parseLevels(r.Asks, false)
+	case Direction_SELL:
+		if r.BidLevels != nil {
+			return r.BidLevels, nil
+		}
+		return parseLevels(r.Bids, true)
+	default:
+		return nil, fmt.Errorf("invalid direction: %v", direction)
+	}
+}
+
+func (r *OrderBookResponse) BestBidPrice() (float64, error) {
+	bids, err := r.takerLevels(Direction_SELL)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(bids) == 0 {
+		return 0, ErrOrderBookEmpty
+	}
+
+	return bids[0].Price, nil
+}
+
+func (r *OrderBookResponse) BestAskPrice() (float64, error) {
+	asks, err := r.takerLevels(Direction_BUY)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(asks) == 0 {
+		return 0, ErrOrderBookEmpty
+	}
+
+	return asks[0].Price, nil
+}
+
+func (r *OrderBookResponse) MidPrice() (float64, error) {
+	bid, err := r.BestBidPrice()
+	if err != nil {
+		return 0, err
+	}
+
+	ask, err := r.BestAskPrice()
+	if err != nil {
+		return 0, err
+	}
+
+	return (bid + ask) / 2, nil
+}
+
+// Spread returns the difference between the best ask and the best bid.
+func (r *OrderBookResponse) Spread() (float64, error) {
+	bid, err := r.BestBidPrice()
+	if err != nil {
+		return 0, err
+	}
+
+	ask, err := r.BestAskPrice()
+	if err != nil {
+		return 0, err
+	}
+
+	return ask - bid, nil
+}
+
+// SpreadBps returns the spread in basis points of the mid price.
+func (r *OrderBookResponse) SpreadBps() (float64, error) {
+	spread, err := r.Spread()
+	if err != nil {
+		return 0, err
+	}
+
+	mid, err := r.MidPrice()
+	if err != nil {
+		return 0, err
+	}
+
+	return spread / mid * 1e4, nil
+}
+
+// DepthToPrice returns the amount an order in direction can fill at limit
+// or better.
+func (r *OrderBookResponse) DepthToPrice(direction Direction, limit float64) (float64, error) {
+	levels, err := r.takerLevels(direction)
+	if err != nil {
+		return 0, err
+	}
+
+	depth := 0.0
+	for _, level := range levels {
+		if direction == Direction_BUY && level.Price > limit {
+			break
+		}
+		if direction == Direction_SELL && level.Price < limit {
+			break
+		}
+
+		depth += level.Amount
+	}
+
+	return depth, nil
+}
+
+// DepthWithinBps returns the amount an order in direction can fill within
+// bps basis points of the mid price.
+func (r *OrderBookResponse) DepthWithinBps(direction Direction, bps float64) (float64, error) {
+	mid, err := r.MidPrice()
+	if err != nil {
+		return 0, err
+	}
+
+	limit := mid * (1 + bps/1e4)
+	if direction == Direction_SELL {
+		limit = mid * (1 - bps/1e4)
+	}
+
+	return r.DepthToPrice(direction, limit)
+}
+
+// AverageFillPrice returns the volume weighted average price of filling
+// amount in direction against the book. It returns ErrInsufficientLiquidity
+// if the book is too thin.
+func (r *OrderBookResponse) AverageFillPrice(direction Direction, amount float64) (float64, error) {
+	levels, err := r.takerLevels(direction)
+	if err != nil {
+		return 0, err
+	}
+
+	if amount <= 0 {
+		return 0, fmt.Errorf("invalid amount: %v", amount)
+	}
+
+	remaining := amount
+	cost := 0.0
+	for _, level := range levels {
+		fill := level.Amount
+		if fill > remaining {
+			fill = remaining
+		}
+
+		cost += fill * level.Price
+		remaining -= fill
+		if remaining <= 0 {
+			return cost / amount, nil
+		}
+	}
+
+	return 0, ErrInsufficientLiquidity
+}
+
+// SlippageBps returns how much worse than the mid price the average fill
+// of amount in direction is, in basis points.
+func (r *OrderBookResponse) SlippageBps(direction Direction, amount float64) (float64, error) {
+	avg, err := r.AverageFillPrice(direction, amount)
+	if err != nil {
+		return 0, err
+	}
+
+	mid, err := r.MidPrice()
+	if err != nil {
+		return 0, err
+	}
+
+	if direction == Direction_SELL {
+		return (mid - avg) / mid * 1e4, nil
+	}
+
+	return (avg - mid) / mid * 1e4, nil
+}
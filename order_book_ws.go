@@ -0,0 +1,526 @@
+package blocktrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The order_book channel, the subscribe_order_book command and the payload
+// below are not covered by the API documentation this client is built from.
+// They are unverified and the channel may not exist on the server.
+const MessageType_OrderBook MessageType = "order_book"
+
+const OrderBookUpdate_SNAPSHOT = "snapshot"
+const OrderBookUpdate_UPDATE = "update"
+
+// ORDER_BOOK_BUFFER_LIMIT is how many updates are buffered while a book is
+// resynced over REST. Older updates are dropped beyond it.
+const ORDER_BOOK_BUFFER_LIMIT = 1000
+
+const ORDER_BOOK_RESYNC_BACKOFF = time.Second
+const ORDER_BOOK_RESYNC_MAX_BACKOFF = 30 * time.Second
+
+type OrderBookHandlerFunc func(orderBook *OrderBook, err error)
+
+// blockTradeOrderBookWsResponse is either a full snapshot of the book or a
+// set of changed levels. A changed level with a zero amount is removed.
+type blockTradeOrderBookWsResponse struct {
+	TradingPairId int64            `json:"trading_pair_id"`
+	Type          string           `json:"type"`
+	Sequence      int64            `json:"sequence"`
+	Asks          []OrderBookEntry `json:"asks"`
+	Bids          []OrderBookEntry `json:"bids"`
+}
+
+type orderBookSubscription struct {
+	book *OrderBook
+	f    OrderBookHandlerFunc
+}
+
+// OrderBook is a local copy of a trading pair's order book kept up to date
+// from the websocket. It is safe for concurrent use.
+type OrderBook struct {
+	TradingPairId int64
+
+	mtx        sync.RWMutex
+	synced     bool
+	resyncing  bool
+	generation int64
+	buffer     []*blockTradeOrderBookWsResponse
+	sequence   int64
+	asks       map[float64]OrderBookEntry
+	bids       map[float64]OrderBookEntry
+}
+
+func newOrderBook(tradingPairId int64) *OrderBook {
+	return &OrderBook{
+		TradingPairId: tradingPairId,
+		asks:          make(map[float64]OrderBookEntry),
+		bids:          make(map[float64]OrderBookEntry),
+	}
+}
+
+// Sequence returns the sequence number of the last applied update.
+func (b *OrderBook) Sequence() int64 {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	return b.sequence
+}
+
+func (b *OrderBook) BestBid() (OrderBookEntry, bool) {
+	bids, _ := b.levels(1)
+	if len(bids) == 0 {
+		return OrderBookEntry{}, false
+	}
+
+	return bids[0], true
+}
+
+func (b *OrderBook) BestAsk() (OrderBookEntry, bool) {
+	_, asks := b.levels(1)
+	if len(asks) == 0 {
+		return OrderBookEntry{}, false
+	}
+
+	return asks[0], true
+}
+
+// Depth returns the best n levels per side, bids descending and asks
+// ascending. A non-positive n returns the whole book.
+func (b *OrderBook) Depth(n int) (*OrderBookResponse, error) {
+	bids, asks := b.levels(n)
+	resp := &OrderBookResponse{
+		Asks: asks,
+		Bids: bids,
+		Time: time.Now(),
+	}
+
+	err := resp.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (b *OrderBook) levels(n int) ([]OrderBookEntry, []OrderBookEntry) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	return sortedLevels(b.bids, true, n), sortedLevels(b.asks, false, n)
+}
+
+func sortedLevels(side map[float64]OrderBookEntry, descending bool, n int) []OrderBookEntry {
+	prices := make([]float64, 0, len(side))
+	for price := range side {
+		prices = append(prices, price)
+	}
+
+	if descending {
+		sort.Sort(sort.Reverse(sort.Float64Slice(prices)))
+	} else {
+		sort.Float64s(prices)
+	}
+
+	if n > 0 && n < len(prices) {
+		prices = prices[:n]
+	}
+
+	entries := make([]OrderBookEntry, 0, len(prices))
+	for _, price := range prices {
+		entries = append(entries, side[price])
+	}
+
+	return entries
+}
+
+// reset replaces the book with a snapshot from the websocket. A resync in
+// progress is abandoned.
+func (b *OrderBook) reset(asks, bids []OrderBookEntry, sequence int64) error {
+	newAsks, newBids, err := snapshotLevels(asks, bids)
+	if err != nil {
+		return err
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.asks = newAsks
+	b.bids = newBids
+	b.sequence = sequence
+	b.synced = true
+	b.resyncing = false
+	b.buffer = nil
+	b.generation++
+
+	return nil
+}
+
+// apply applies an incremental update. While the book is resyncing the
+// update is buffered. If the update does not directly follow the last one
+// the book starts resyncing with it buffered, and apply reports the
+// generation the resync has to finish. Invalid updates are rejected before
+// they are buffered or applied.
+func (b *OrderBook) apply(update *blockTradeOrderBookWsResponse) (bool, int64, error) {
+	err := validateUpdate(update)
+	if err != nil {
+		return false, 0, err
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.resyncing {
+		b.bufferUpdate(update)
+		return false, 0, nil
+	}
+
+	if !b.synced || update.Sequence != b.sequence+1 {
+		b.synced = false
+		b.resyncing = true
+		b.generation++
+		b.buffer = nil
+		b.bufferUpdate(update)
+		return true, b.generation, nil
+	}
+
+	applyUpdate(b.asks, b.bids, update)
+	b.sequence = update.Sequence
+
+	return false, 0, nil
+}
+
+func (b *OrderBook) bufferUpdate(update *blockTradeOrderBookWsResponse) {
+	if len(b.buffer) >= ORDER_BOOK_BUFFER_LIMIT {
+		b.buffer = b.buffer[1:]
+	}
+	b.buffer = append(b.buffer, update)
+}
+
+// finishResync replaces the book with a REST snapshot and replays the
+// buffered updates on it. Updates carry absolute level amounts, so replaying
+// one the snapshot already contains is harmless. The sequence continues from
+// the last buffered update. It reports false if the buffer has a gap and the
+// snapshot needs to be fetched again, and stale if the resync was abandoned.
+func (b *OrderBook) finishResync(snapshot *OrderBookResponse, generation int64) (ok bool, stale bool, err error) {
+	asks, bids, err := snapshotLevels(snapshot.Asks, snapshot.Bids)
+	if err != nil {
+		return false, false, err
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if !b.resyncing || b.generation != generation {
+		return false, true, nil
+	}
+
+	for i := 1; i < len(b.buffer); i++ {
+		if b.buffer[i].Sequence != b.buffer[i-1].Sequence+1 {
+			b.buffer = b.buffer[i:]
+			return false, false, nil
+		}
+	}
+
+	for _, update := range b.buffer {
+		applyUpdate(asks, bids, update)
+	}
+
+	b.asks = asks
+	b.bids = bids
+	b.sequence = b.buffer[len(b.buffer)-1].Sequence
+	b.synced = true
+	b.resyncing = false
+	b.buffer = nil
+
+	return true, false, nil
+}
+
+func snapshotLevels(asks, bids []OrderBookEntry) (map[float64]OrderBookEntry, map[float64]OrderBookEntry, error) {
+	newAsks := make(map[float64]OrderBookEntry)
+	newBids := make(map[float64]OrderBookEntry)
+
+	err := applyLevels(newAsks, asks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = applyLevels(newBids, bids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return newAsks, newBids, nil
+}
+
+func validateUpdate(update *blockTradeOrderBookWsResponse) error {
+	_, err := parseLevels(update.Asks, false)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseLevels(update.Bids, true)
+	return err
+}
+
+// applyUpdate applies both sides of an update validated by validateUpdate.
+func applyUpdate(asks, bids map[float64]OrderBookEntry, update *blockTradeOrderBookWsResponse) {
+	applyLevels(asks, update.Asks)
+	applyLevels(bids, update.Bids)
+}
+
+// applyLevels sets the levels of entries on side, removing zero levels. The
+// entries are validated before side is changed.
+func applyLevels(side map[float64]OrderBookEntry, entries []OrderBookEntry) error {
+	prices := make([]float64, 0, len(entries))
+	amounts := make([]float64, 0, len(entries))
+	for _, entry := range entries {
+		price, err := strconv.ParseFloat(entry.Price, 64)
+		if err != nil {
+			return err
+		}
+
+		amount, err := strconv.ParseFloat(entry.Amount, 64)
+		if err != nil {
+			return err
+		}
+
+		if price <= 0 || amount < 0 {
+			return fmt.Errorf("invalid order book level: price %v amount %v", entry.Price, entry.Amount)
+		}
+
+		prices = append(prices, price)
+		amounts = append(amounts, amount)
+	}
+
+	for i, entry := range entries {
+		if amounts[i] == 0 {
+			delete(side, prices[i])
+		} else {
+			side[prices[i]] = entry
+		}
+	}
+
+	return nil
+}
+
+// resyncOrderBook fetches the book over REST until it succeeds, backing off
+// after failures, and replays the updates buffered meanwhile. It runs off
+// the dispatcher and ends early when the subscription or websocket goes
+// away or a websocket snapshot arrives.
+func (a *APIClient) resyncOrderBook(ws *wsSession, sub *orderBookSubscription, generation int64) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-ws.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	backoff := ORDER_BOOK_RESYNC_BACKOFF
+	for {
+		snapshot, err := a.getOrderBook(ctx, sub.book.TradingPairId)
+		if err == nil {
+			var ok, stale bool
+			ok, stale, err = sub.book.finishResync(snapshot, generation)
+			if stale {
+				return
+			}
+
+			if ok {
+				a.notifyOrderBook(sub, sub.book, nil)
+				return
+			}
+
+			if err == nil {
+				// the buffer had a gap, fetch a newer snapshot right away
+				backoff = ORDER_BOOK_RESYNC_BACKOFF
+				continue
+			}
+		}
+
+		if !a.notifyOrderBook(sub, nil, fmt.Errorf("order book resync failed: %w", err)) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > ORDER_BOOK_RESYNC_MAX_BACKOFF {
+			backoff = ORDER_BOOK_RESYNC_MAX_BACKOFF
+		}
+	}
+}
+
+// notifyOrderBook calls the subscription's handler with wsHandlerMtx held,
+// like the dispatcher does. It reports false if the subscription was
+// removed.
+func (a *APIClient) notifyOrderBook(sub *orderBookSubscription, book *OrderBook, err error) bool {
+	a.wsHandlerMtx.Lock()
+	defer a.wsHandlerMtx.Unlock()
+
+	if a.wsOrderBooks[sub.book.TradingPairId] != sub {
+		return false
+	}
+
+	sub.f(book, err)
+	return true
+}
+
+// handleOrderBookMessage is called by the websocket dispatcher with
+// wsHandlerMtx held. It must not block, resyncs run on their own goroutine.
+func (a *APIClient) handleOrderBookMessage(ws *wsSession, payload map[string]interface{}) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	resp := new(blockTradeOrderBookWsResponse)
+	err = json.Unmarshal(b, &resp)
+	if err != nil {
+		for _, sub := range a.wsOrderBooks {
+			sub.f(nil, err)
+		}
+		return
+	}
+
+	sub, ok := a.wsOrderBooks[resp.TradingPairId]
+	if !ok {
+		return
+	}
+
+	if resp.Type == OrderBookUpdate_SNAPSHOT {
+		err = sub.book.reset(resp.Asks, resp.Bids, resp.Sequence)
+		if err != nil {
+			sub.f(nil, err)
+			return
+		}
+
+		sub.f(sub.book, nil)
+		return
+	}
+
+	resync, generation, err := sub.book.apply(resp)
+	if err != nil {
+		sub.f(nil, err)
+		return
+	}
+
+	if resync {
+		ws.goroutine(func() {
+			a.resyncOrderBook(ws, sub, generation)
+		})
+		return
+	}
+
+	if sub.book.isSynced() {
+		sub.f(sub.book, nil)
+	}
+}
+
+func (b *OrderBook) isSynced() bool {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	return b.synced
+}
+
+// SubscribeOrderBook keeps a local order book for the trading pair up to
+// date and calls f after every change. The book is resynced over REST when
+// an update is missed, f is then called from the resync goroutine.
+func (a *APIClient) SubscribeOrderBook(tradingPairId int64, f OrderBookHandlerFunc) (*OrderBook, error) {
+	book, params, restore, err := a.subscribeOrderBookParams(tradingPairId, f)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.writeWsCommand("subscribe_order_book", params)
+	if err != nil {
+		restore()
+		return nil, err
+	}
+
+	return book, nil
+}
+
+// SubscribeOrderBookContext is like SubscribeOrderBook but waits for the
+// server to acknowledge the subscription.
+func (a *APIClient) SubscribeOrderBookContext(ctx context.Context, tradingPairId int64, f OrderBookHandlerFunc) (*OrderBook, error) {
+	book, params, restore, err := a.subscribeOrderBookParams(tradingPairId, f)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.writeWsCommandAck(ctx, "subscribe_order_book", params)
+	if err != nil {
+		restore()
+		return nil, err
+	}
+
+	return book, nil
+}
+
+func (a *APIClient) subscribeOrderBookParams(tradingPairId int64, f OrderBookHandlerFunc) (*OrderBook, map[string]interface{}, func(), error) {
+	if a.currentWs() == nil {
+		return nil, nil, nil, ErrWebsocketNotInitialized
+	}
+
+	book := newOrderBook(tradingPairId)
+	sub := &orderBookSubscription{book: book, f: f}
+
+	a.wsHandlerMtx.Lock()
+	prev, ok := a.wsOrderBooks[tradingPairId]
+	a.wsOrderBooks[tradingPairId] = sub
+	a.wsHandlerMtx.Unlock()
+
+	restore := func() {
+		a.wsHandlerMtx.Lock()
+		defer a.wsHandlerMtx.Unlock()
+
+		if a.wsOrderBooks[tradingPairId] != sub {
+			return
+		}
+
+		if ok {
+			a.wsOrderBooks[tradingPairId] = prev
+		} else {
+			delete(a.wsOrderBooks, tradingPairId)
+		}
+	}
+
+	params := map[string]interface{}{
+		"trading_pair_id": tradingPairId,
+	}
+
+	return book, params, restore, nil
+}
+
+func (a *APIClient) UnsubscribeOrderBook(tradingPairId int64) error {
+	params := map[string]interface{}{
+		"trading_pair_id": tradingPairId,
+	}
+
+	err := a.writeWsCommand("unsubscribe_order_book", params)
+	if err != nil {
+		return err
+	}
+
+	a.wsHandlerMtx.Lock()
+	delete(a.wsOrderBooks, tradingPairId)
+	a.wsHandlerMtx.Unlock()
+
+	return nil
+}
@@ -0,0 +1,373 @@
+package blocktrade
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+type CostBasis string
+
+const CostBasis_FIFO CostBasis = "FIFO"
+const CostBasis_LIFO CostBasis = "LIFO"
+const CostBasis_AVERAGE CostBasis = "AVERAGE"
+
+// Lot is an open position from one buy. Price is the cost per unit in the
+// quote asset including the fee.
+type Lot struct {
+	TradeId int64
+	Date    int64
+	Amount  float64
+	Price   float64
+}
+
+// ClosedLot is the part of a lot closed by a later sell. Cost and Proceeds
+// include the fees of both trades, Gain is Proceeds minus Cost. A sell of
+// more than the open lots hold closes the rest with CostUnknown set, no open
+// trade and zero Cost and Gain.
+type ClosedLot struct {
+	OpenTradeId  int64
+	OpenDate     int64
+	CloseTradeId int64
+	CloseDate    int64
+	Amount       float64
+	Cost         float64
+	Proceeds     float64
+	Gain         float64
+	CostUnknown  bool
+}
+
+// FillPnL is the result of applying one trade.
+type FillPnL struct {
+	Trade      *TradeResponse
+	Fee        float64
+	Realised   float64
+	ClosedLots []*ClosedLot
+}
+
+// PairPnL is the profit and loss of one trading pair in the quote asset.
+// Unmatched is the amount sold without a matching buy, whose gain is not
+// included in Realised.
+type PairPnL struct {
+	TradingPairId int64
+	Position      float64
+	AverageCost   float64
+	Realised      float64
+	Fees          float64
+	MarkPrice     float64
+	Unrealised    float64
+	Unmatched     float64
+}
+
+type pairLots struct {
+	lots      []*Lot
+	realised  float64
+	fees      float64
+	unmatched float64
+}
+
+// PnLCalculator computes realised and unrealised profit and loss per
+// trading pair from TradeResponse fills. Fees are attributed in the quote
+// asset: they raise the cost of opened lots and reduce the proceeds of
+// closing trades. Use one calculator per portfolio. It is safe for
+// concurrent use.
+type PnLCalculator struct {
+	mtx    sync.Mutex
+	method CostBasis
+	pairs  map[int64]*pairLots
+	marks  map[int64]float64
+	trades map[int64]bool
+}
+
+// NewPnLCalculator returns a calculator matching closing trades to open lots
+// by method.
+func NewPnLCalculator(method CostBasis) (*PnLCalculator, error) {
+	switch method {
+	case CostBasis_FIFO, CostBasis_LIFO, CostBasis_AVERAGE:
+	default:
+		return nil, fmt.Errorf("unknown cost basis: %v", method)
+	}
+
+	return &PnLCalculator{
+		method: method,
+		pairs:  make(map[int64]*pairLots),
+		marks:  make(map[int64]float64),
+		trades: make(map[int64]bool),
+	}, nil
+}
+
+// AddTrades applies historical trades in the order they happened.
+func (c *PnLCalculator) AddTrades(trades []*TradeResponse) ([]*FillPnL, error) {
+	sorted := append([]*TradeResponse(nil), trades...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Date != sorted[j].Date {
+			return sorted[i].Date < sorted[j].Date
+		}
+		return sorted[i].Id < sorted[j].Id
+	})
+
+	fills := make([]*FillPnL, 0, len(sorted))
+	for _, trade := range sorted {
+		fill, err := c.AddTrade(trade)
+		if err != nil {
+			return nil, err
+		}
+
+		if fill != nil {
+			fills = append(fills, fill)
+		}
+	}
+
+	return fills, nil
+}
+
+// AddTrade applies a trade and returns its realised profit and loss. Trades
+// already applied return nil.
+func (c *PnLCalculator) AddTrade(trade *TradeResponse) (*FillPnL, error) {
+	amount, err := parseOptionalFloat(trade.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := parseOptionalFloat(trade.Price)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := parseOptionalFloat(trade.FeeValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount <= 0 {
+		return nil, fmt.Errorf("invalid trade amount: %v", trade.Amount)
+	}
+
+	// cost per unit of a buy and proceeds per unit of a sell, net of fees
+	unitPrice := (amount*price + fee) / amount
+	if trade.Direction == Direction_SELL {
+		unitPrice = (amount*price - fee) / amount
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.trades[trade.Id] {
+		return nil, nil
+	}
+	c.trades[trade.Id] = true
+
+	pair := c.pair(trade.TradingPairId)
+	pair.fees += fee
+	c.marks[trade.TradingPairId] = price
+
+	fill := &FillPnL{
+		Trade: trade,
+		Fee:   fee,
+	}
+
+	if trade.Direction != Direction_SELL {
+		c.open(pair, &Lot{
+			TradeId: trade.Id,
+			Date:    trade.Date,
+			Amount:  amount,
+			Price:   unitPrice,
+		})
+
+		return fill, nil
+	}
+
+	// a sell closes open lots, accounts cannot go short
+	remaining := amount
+	for remaining != 0 && len(pair.lots) > 0 {
+		i := 0
+		if c.method == CostBasis_LIFO {
+			i = len(pair.lots) - 1
+		}
+		lot := pair.lots[i]
+
+		closed := math.Min(lot.Amount, remaining)
+		closedLot := &ClosedLot{
+			OpenTradeId:  lot.TradeId,
+			OpenDate:     lot.Date,
+			CloseTradeId: trade.Id,
+			CloseDate:    trade.Date,
+			Amount:       closed,
+			Cost:         closed * lot.Price,
+			Proceeds:     closed * unitPrice,
+		}
+		closedLot.Gain = closedLot.Proceeds - closedLot.Cost
+
+		fill.ClosedLots = append(fill.ClosedLots, closedLot)
+		fill.Realised += closedLot.Gain
+
+		// closed equals one of the two amounts exactly, zero it without
+		// leaving rounding residue
+		if closed == remaining {
+			remaining = 0
+		} else {
+			remaining -= closed
+		}
+
+		if closed == lot.Amount {
+			pair.lots = append(pair.lots[:i], pair.lots[i+1:]...)
+		} else {
+			lot.Amount -= closed
+		}
+	}
+
+	if remaining != 0 {
+		// the holdings were bought outside the applied trades
+		fill.ClosedLots = append(fill.ClosedLots, &ClosedLot{
+			CloseTradeId: trade.Id,
+			CloseDate:    trade.Date,
+			Amount:       remaining,
+			Proceeds:     remaining * unitPrice,
+			CostUnknown:  true,
+		})
+		pair.unmatched += remaining
+	}
+
+	pair.realised += fill.Realised
+
+	return fill, nil
+}
+
+func (c *PnLCalculator) open(pair *pairLots, lot *Lot) {
+	if c.method != CostBasis_AVERAGE || len(pair.lots) == 0 {
+		pair.lots = append(pair.lots, lot)
+		return
+	}
+
+	avg := pair.lots[0]
+	total := avg.Amount + lot.Amount
+	avg.Price = (avg.Amount*avg.Price + lot.Amount*lot.Price) / total
+	avg.Amount = total
+}
+
+// Mark sets the price unrealised profit and loss is computed at.
+func (c *PnLCalculator) Mark(tradingPairId int64, price float64) {
+	c.mtx.Lock()
+	c.marks[tradingPairId] = price
+	c.mtx.Unlock()
+}
+
+// MarkTicker marks the ticker's pair to its last price.
+func (c *PnLCalculator) MarkTicker(ticker *TickerResponse) error {
+	price, err := tickerPrice(&ticker.Data)
+	if err != nil {
+		return err
+	}
+
+	if price > 0 {
+		c.Mark(ticker.TradingPairId, price)
+	}
+
+	return nil
+}
+
+// OpenLots returns a copy of the open lots of a trading pair.
+func (c *PnLCalculator) OpenLots(tradingPairId int64) []Lot {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	lots := make([]Lot, 0)
+	if pair, ok := c.pairs[tradingPairId]; ok {
+		for _, lot := range pair.lots {
+			lots = append(lots, *lot)
+		}
+	}
+
+	return lots
+}
+
+// PnL returns the profit and loss of a trading pair, all zero if it was not
+// traded.
+func (c *PnLCalculator) PnL(tradingPairId int64) *PairPnL {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	pair, ok := c.pairs[tradingPairId]
+	if !ok {
+		pair = &pairLots{}
+	}
+
+	return c.pnl(tradingPairId, pair)
+}
+
+// All returns the profit and loss of every traded pair sorted by pair id.
+func (c *PnLCalculator) All() []*PairPnL {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	all := make([]*PairPnL, 0, len(c.pairs))
+	for tradingPairId, pair := range c.pairs {
+		all = append(all, c.pnl(tradingPairId, pair))
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].TradingPairId < all[j].TradingPairId
+	})
+
+	return all
+}
+
+func (c *PnLCalculator) pnl(tradingPairId int64, pair *pairLots) *PairPnL {
+	pnl := &PairPnL{
+		TradingPairId: tradingPairId,
+		Realised:      pair.realised,
+		Fees:          pair.fees,
+		MarkPrice:     c.marks[tradingPairId],
+		Unmatched:     pair.unmatched,
+	}
+
+	cost := 0.0
+	for _, lot := range pair.lots {
+		pnl.Position += lot.Amount
+		cost += lot.Amount * lot.Price
+	}
+
+	if pnl.Position != 0 {
+		pnl.AverageCost = cost / pnl.Position
+		pnl.Unrealised = pnl.Position * (pnl.MarkPrice - pnl.AverageCost)
+	}
+
+	return pnl
+}
+
+func (c *PnLCalculator) pair(tradingPairId int64) *pairLots {
+	pair, ok := c.pairs[tradingPairId]
+	if !ok {
+		pair = &pairLots{}
+		c.pairs[tradingPairId] = pair
+	}
+
+	return pair
+}
+
+// TradeHandler returns a handler for SubscribeUserTrades. The stream carries
+// the trades of every portfolio of the account and TradeResponse has no
+// portfolio id, so the handler is only correct for accounts with a single
+// portfolio. Otherwise pass the portfolio's trades to AddTrade, matched by
+// their OrderId to the portfolio's orders.
+func (c *PnLCalculator) TradeHandler() UserTradeHandlerFunc {
+	return func(trade *TradeResponse, err error) {
+		if err != nil {
+			return
+		}
+
+		c.AddTrade(trade)
+	}
+}
+
+// TickerHandler returns a handler for SubscribeTicker marking the prices.
+func (c *PnLCalculator) TickerHandler() TickerHandlerFunc {
+	return func(ticker *TickerResponse, err error) {
+		if err != nil {
+			return
+		}
+
+		c.MarkTicker(ticker)
+	}
+}
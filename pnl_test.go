@@ -0,0 +1,167 @@
+package blocktrade
+
+import (
+	"math"
+	"testing"
+)
+
+func pnlTrade(id int64, direction Direction, amount, price, fee string) *TradeResponse {
+	return &TradeResponse{
+		Id:            id,
+		TradingPairId: 1,
+		Direction:     direction,
+		Amount:        amount,
+		Price:         price,
+		FeeValue:      fee,
+		Date:          id,
+	}
+}
+
+func floatEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPnLCalculator(t *testing.T) {
+	partialClose := []*TradeResponse{
+		pnlTrade(1, Direction_BUY, "2", "10", "0"),
+		pnlTrade(2, Direction_BUY, "1", "20", "0"),
+		pnlTrade(3, Direction_SELL, "2.5", "30", "0"),
+	}
+
+	tests := []struct {
+		name   string
+		method CostBasis
+		trades []*TradeResponse
+		lots   []ClosedLot
+		pnl    PairPnL
+	}{
+		{
+			name:   "fifo partial close",
+			method: CostBasis_FIFO,
+			trades: partialClose,
+			lots: []ClosedLot{
+				{OpenTradeId: 1, CloseTradeId: 3, Amount: 2, Cost: 20, Proceeds: 60, Gain: 40},
+				{OpenTradeId: 2, CloseTradeId: 3, Amount: 0.5, Cost: 10, Proceeds: 15, Gain: 5},
+			},
+			pnl: PairPnL{Position: 0.5, AverageCost: 20, Realised: 45, MarkPrice: 30, Unrealised: 5},
+		},
+		{
+			name:   "lifo closes the newest lot first",
+			method: CostBasis_LIFO,
+			trades: partialClose,
+			lots: []ClosedLot{
+				{OpenTradeId: 2, CloseTradeId: 3, Amount: 1, Cost: 20, Proceeds: 30, Gain: 10},
+				{OpenTradeId: 1, CloseTradeId: 3, Amount: 1.5, Cost: 15, Proceeds: 45, Gain: 30},
+			},
+			pnl: PairPnL{Position: 0.5, AverageCost: 10, Realised: 40, MarkPrice: 30, Unrealised: 10},
+		},
+		{
+			name:   "average merges the lots",
+			method: CostBasis_AVERAGE,
+			trades: partialClose,
+			lots: []ClosedLot{
+				{OpenTradeId: 1, CloseTradeId: 3, Amount: 2.5, Cost: 2.5 * 40 / 3, Proceeds: 75, Gain: 75 - 2.5*40/3},
+			},
+			pnl: PairPnL{Position: 0.5, AverageCost: 40.0 / 3, Realised: 75 - 2.5*40/3, MarkPrice: 30, Unrealised: 0.5 * (30 - 40.0/3)},
+		},
+		{
+			name:   "fees raise cost and reduce proceeds",
+			method: CostBasis_FIFO,
+			trades: []*TradeResponse{
+				pnlTrade(1, Direction_BUY, "1", "100", "1"),
+				pnlTrade(2, Direction_SELL, "1", "110", "1.1"),
+			},
+			lots: []ClosedLot{
+				{OpenTradeId: 1, CloseTradeId: 2, Amount: 1, Cost: 101, Proceeds: 108.9, Gain: 7.9},
+			},
+			pnl: PairPnL{Realised: 7.9, Fees: 2.1, MarkPrice: 110},
+		},
+		{
+			name:   "sell without a buy has unknown cost",
+			method: CostBasis_FIFO,
+			trades: []*TradeResponse{
+				pnlTrade(1, Direction_BUY, "1", "10", "0"),
+				pnlTrade(2, Direction_SELL, "3", "20", "0"),
+				pnlTrade(3, Direction_BUY, "1", "30", "0"),
+			},
+			lots: []ClosedLot{
+				{OpenTradeId: 1, CloseTradeId: 2, Amount: 1, Cost: 10, Proceeds: 20, Gain: 10},
+				{CloseTradeId: 2, Amount: 2, Proceeds: 40, CostUnknown: true},
+			},
+			pnl: PairPnL{Position: 1, AverageCost: 30, Realised: 10, MarkPrice: 30, Unmatched: 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewPnLCalculator(test.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fills, err := c.AddTrades(test.trades)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lots := make([]ClosedLot, 0)
+			for _, fill := range fills {
+				for _, lot := range fill.ClosedLots {
+					lots = append(lots, *lot)
+				}
+			}
+
+			if len(lots) != len(test.lots) {
+				t.Fatalf("got %d closed lots, want %d: %+v", len(lots), len(test.lots), lots)
+			}
+
+			for i, want := range test.lots {
+				got := lots[i]
+				if got.OpenTradeId != want.OpenTradeId || got.CloseTradeId != want.CloseTradeId || got.CostUnknown != want.CostUnknown ||
+					!floatEqual(got.Amount, want.Amount) || !floatEqual(got.Cost, want.Cost) ||
+					!floatEqual(got.Proceeds, want.Proceeds) || !floatEqual(got.Gain, want.Gain) {
+					t.Errorf("closed lot %d: got %+v, want %+v", i, got, want)
+				}
+			}
+
+			got := c.PnL(1)
+			want := test.pnl
+			if !floatEqual(got.Position, want.Position) || !floatEqual(got.AverageCost, want.AverageCost) ||
+				!floatEqual(got.Realised, want.Realised) || !floatEqual(got.Fees, want.Fees) ||
+				!floatEqual(got.MarkPrice, want.MarkPrice) || !floatEqual(got.Unrealised, want.Unrealised) ||
+				!floatEqual(got.Unmatched, want.Unmatched) {
+				t.Errorf("pnl: got %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestPnLCalculatorMarkDoesNotAddPairs(t *testing.T) {
+	c, err := NewPnLCalculator(CostBasis_FIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Mark(2, 100)
+	c.PnL(3)
+
+	if all := c.All(); len(all) != 0 {
+		t.Fatalf("untraded pairs listed: %+v", all)
+	}
+
+	_, err = c.AddTrade(pnlTrade(1, Direction_BUY, "1", "10", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if all := c.All(); len(all) != 1 || all[0].TradingPairId != 1 {
+		t.Fatalf("got %+v, want pair 1 only", all)
+	}
+}
+
+func TestNewPnLCalculatorRejectsUnknownCostBasis(t *testing.T) {
+	_, err := NewPnLCalculator("HIFO")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -1,9 +1,16 @@
 package blocktrade
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
 
 const PORTFOLIOS_ENDPOINT = "/portfolios"
 
+var ErrNoPortfolio = errors.New("no portfolio found")
+
 type Portfolio struct {
 	Id     int64             `json:"id"`
 	Assets []*PortfolioAsset `json:"assets"`
@@ -17,7 +24,11 @@ type PortfolioAsset struct {
 }
 
 func (a *APIClient) Portfolios() ([]*Portfolio, error) {
-	b, err := a.requestGET(PORTFOLIOS_ENDPOINT)
+	return a.portfolios(context.Background())
+}
+
+func (a *APIClient) portfolios(ctx context.Context) ([]*Portfolio, error) {
+	b, err := a.requestGETContext(ctx, PORTFOLIOS_ENDPOINT)
 	if err != nil {
 		return nil, err
 	}
@@ -27,17 +38,89 @@ func (a *APIClient) Portfolios() ([]*Portfolio, error) {
 	return resp, err
 }
 
+// GetPortfolioId returns the default portfolio id, which is the first
+// portfolio of the account unless set with SetDefaultPortfolioId or one of
+// the Select methods.
 func (a *APIClient) GetPortfolioId() (int64, error) {
-	if a.portfolio != nil {
-		return a.portfolio.Id, nil
+	a.portfolioMtx.Lock()
+	defer a.portfolioMtx.Unlock()
+
+	if a.portfolioId != 0 {
+		return a.portfolioId, nil
 	}
 
-	portfolio, err := a.Portfolios()
+	portfolios, err := a.Portfolios()
 	if err != nil {
 		return 0, err
 	}
 
-	a.portfolio = portfolio[0]
+	if len(portfolios) == 0 {
+		return 0, ErrNoPortfolio
+	}
+
+	a.portfolioId = portfolios[0].Id
+
+	return a.portfolioId, nil
+}
+
+// SetDefaultPortfolioId sets the portfolio used when no portfolio is given.
+func (a *APIClient) SetDefaultPortfolioId(id int64) {
+	a.portfolioMtx.Lock()
+	a.portfolioId = id
+	a.portfolioMtx.Unlock()
+}
+
+// FindPortfolio returns the first portfolio for which match returns true.
+func (a *APIClient) FindPortfolio(ctx context.Context, match func(portfolio *Portfolio) bool) (*Portfolio, error) {
+	portfolios, err := a.portfolios(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(portfolios) == 0 {
+		return nil, ErrNoPortfolio
+	}
+
+	for _, portfolio := range portfolios {
+		if match(portfolio) {
+			return portfolio, nil
+		}
+	}
+
+	return nil, ErrNoPortfolio
+}
+
+func (a *APIClient) GetPortfolio(ctx context.Context, id int64) (*Portfolio, error) {
+	portfolio, err := a.FindPortfolio(ctx, func(portfolio *Portfolio) bool {
+		return portfolio.Id == id
+	})
+	if err == ErrNoPortfolio {
+		return nil, fmt.Errorf("%w for id %d", ErrNoPortfolio, id)
+	}
+
+	return portfolio, err
+}
+
+// SelectPortfolio makes the portfolio with id the default after checking
+// that it exists.
+func (a *APIClient) SelectPortfolio(ctx context.Context, id int64) error {
+	_, err := a.GetPortfolio(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	a.SetDefaultPortfolioId(id)
+	return nil
+}
+
+// SelectPortfolioFunc makes the first portfolio for which match returns
+// true the default.
+func (a *APIClient) SelectPortfolioFunc(ctx context.Context, match func(portfolio *Portfolio) bool) (*Portfolio, error) {
+	portfolio, err := a.FindPortfolio(ctx, match)
+	if err != nil {
+		return nil, err
+	}
 
-	return a.portfolio.Id, nil
+	a.SetDefaultPortfolioId(portfolio.Id)
+	return portfolio, nil
 }
@@ -0,0 +1,102 @@
+package blocktrade
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SymbolResolver maps symbols in BASE/QUOTE form, e.g. BTC/EUR, to trading
+// pairs and back using the client's asset and pair caches.
+type SymbolResolver struct {
+	client *APIClient
+}
+
+func NewSymbolResolver(client *APIClient) *SymbolResolver {
+	return &SymbolResolver{
+		client: client,
+	}
+}
+
+// ParseSymbol splits a BASE/QUOTE symbol into its upper case iso codes.
+func ParseSymbol(symbol string) (string, string, error) {
+	parts := strings.Split(symbol, "/")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid symbol: %v", symbol)
+	}
+
+	base := strings.ToUpper(strings.TrimSpace(parts[0]))
+	quote := strings.ToUpper(strings.TrimSpace(parts[1]))
+	if base == "" || quote == "" {
+		return "", "", fmt.Errorf("invalid symbol: %v", symbol)
+	}
+
+	return base, quote, nil
+}
+
+func (r *SymbolResolver) TradingPair(symbol string) (*TradingPair, error) {
+	baseCode, quoteCode, err := ParseSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := r.client.TradingAssetFromCode(baseCode)
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := r.client.TradingAssetFromCode(quoteCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.client.TradingPairFromBaseQuote(base.Id, quote.Id)
+}
+
+func (r *SymbolResolver) TradingPairId(symbol string) (int64, error) {
+	pair, err := r.TradingPair(symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	return pair.Id, nil
+}
+
+func (r *SymbolResolver) Symbol(pair *TradingPair) (string, error) {
+	return r.client.pairSymbol(pair)
+}
+
+func (r *SymbolResolver) SymbolFromId(tradingPairId int64) (string, error) {
+	pair, err := r.client.TradingPairFromId(tradingPairId)
+	if err != nil {
+		return "", err
+	}
+
+	return r.client.pairSymbol(pair)
+}
+
+func (a *APIClient) GetTickerBySymbol(symbol string) (*TickerData, error) {
+	tradingPairId, err := NewSymbolResolver(a).TradingPairId(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.GetTicker(tradingPairId)
+}
+
+func (a *APIClient) GetOrderBookBySymbol(symbol string) (*OrderBookResponse, error) {
+	tradingPairId, err := NewSymbolResolver(a).TradingPairId(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.GetOrderBook(tradingPairId)
+}
+
+func (a *APIClient) SubscribeTickerBySymbol(symbol string, f TickerHandlerFunc) error {
+	tradingPairId, err := NewSymbolResolver(a).TradingPairId(symbol)
+	if err != nil {
+		return err
+	}
+
+	return a.SubscribeTicker(tradingPairId, f)
+}
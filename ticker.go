@@ -1,12 +1,19 @@
 package blocktrade
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 )
 
 const TICKER_ENDPOINT = "/ticker"
 
+// TICKER_CONCURRENCY limits parallel requests in GetTickers to stay within
+// the API rate limits.
+const TICKER_CONCURRENCY = 4
+
 type TickerData struct {
 	AskPrice  string `json:"ask_price"`
 	BidPrice  string `json:"bid_price"`
@@ -22,8 +29,12 @@ type TickerResponse struct {
 }
 
 func (a *APIClient) GetTicker(tradingPairId int64) (*TickerData, error) {
+	return a.getTicker(context.Background(), tradingPairId)
+}
+
+func (a *APIClient) getTicker(ctx context.Context, tradingPairId int64) (*TickerData, error) {
 	url := fmt.Sprintf("%v/%d", TICKER_ENDPOINT, tradingPairId)
-	b, err := a.requestPublicGET(url)
+	b, err := a.requestPublicGETContext(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -32,3 +43,77 @@ func (a *APIClient) GetTicker(tradingPairId int64) (*TickerData, error) {
 	err = json.Unmarshal(b, resp)
 	return resp, err
 }
+
+// GetTickers fetches the tickers of the given trading pairs, or of all
+// trading pairs if none are given, in the order of tradingPairIds.
+func (a *APIClient) GetTickers(ctx context.Context, tradingPairIds ...int64) ([]*TickerResponse, error) {
+	if len(tradingPairIds) == 0 {
+		pairs, err := a.cachedPairs(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pair := range pairs {
+			tradingPairIds = append(tradingPairIds, pair.Id)
+		}
+	}
+
+	resp, errs := a.fetchTickers(ctx, tradingPairIds, true)
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, fmt.Errorf("ticker for pair %d: %w", tradingPairIds[i], err)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return resp, nil
+}
+
+// fetchTickers fetches the tickers of tradingPairIds concurrently. A failed
+// ticker is nil and has its error at the same index, with stopOnError the
+// remaining requests are cancelled and fail with context.Canceled.
+func (a *APIClient) fetchTickers(ctx context.Context, tradingPairIds []int64, stopOnError bool) ([]*TickerResponse, []error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resp := make([]*TickerResponse, len(tradingPairIds))
+	errs := make([]error, len(tradingPairIds))
+	sem := make(chan struct{}, TICKER_CONCURRENCY)
+
+	var wg sync.WaitGroup
+	for i, tradingPairId := range tradingPairIds {
+		wg.Add(1)
+		go func(i int, tradingPairId int64) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			data, err := a.getTicker(ctx, tradingPairId)
+			if err != nil {
+				errs[i] = err
+				if stopOnError {
+					cancel()
+				}
+				return
+			}
+
+			resp[i] = &TickerResponse{
+				TradingPairId: tradingPairId,
+				Data:          *data,
+			}
+		}(i, tradingPairId)
+	}
+
+	wg.Wait()
+
+	return resp, errs
+}
@@ -0,0 +1,207 @@
+package blocktrade
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// TradeExporter writes TradeResponse history as CSV reports for
+// accounting. Dates are written as ISO-8601 in Location and realised gains
+// are matched per tax lot using CostBasis. Fees are in the quote asset.
+type TradeExporter struct {
+	client *APIClient
+
+	Location  *time.Location
+	CostBasis CostBasis
+}
+
+// NewTradeExporter returns an exporter using UTC and FIFO.
+func NewTradeExporter(client *APIClient) *TradeExporter {
+	return &TradeExporter{
+		client:    client,
+		Location:  time.UTC,
+		CostBasis: CostBasis_FIFO,
+	}
+}
+
+// WriteTrades writes one row per trade.
+func (e *TradeExporter) WriteTrades(w io.Writer, trades []*TradeResponse) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"trade_id", "order_id", "date", "base_asset", "quote_asset", "direction", "amount", "price", "value", "fee", "fee_asset", "maker"})
+
+	for _, trade := range trades {
+		base, quote, err := e.assets(trade.TradingPairId)
+		if err != nil {
+			return err
+		}
+
+		value := trade.TradeValue
+		if value == "" {
+			notional, err := tradeNotional(trade)
+			if err != nil {
+				return err
+			}
+			value = formatPrecision(notional, quote)
+		}
+
+		writer.Write([]string{
+			fmt.Sprint(trade.Id),
+			fmt.Sprint(trade.OrderId),
+			e.date(trade.Date),
+			base.IsoCode,
+			quote.IsoCode,
+			string(trade.Direction),
+			trade.Amount,
+			trade.Price,
+			value,
+			trade.FeeValue,
+			quote.IsoCode,
+			fmt.Sprint(trade.Make),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteFees writes one row per trade that was charged a fee.
+func (e *TradeExporter) WriteFees(w io.Writer, trades []*TradeResponse) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"trade_id", "date", "base_asset", "quote_asset", "role", "fee", "fee_asset"})
+
+	for _, trade := range trades {
+		fee, err := parseOptionalFloat(trade.FeeValue)
+		if err != nil {
+			return err
+		}
+
+		if fee == 0 {
+			continue
+		}
+
+		base, quote, err := e.assetCodes(trade.TradingPairId)
+		if err != nil {
+			return err
+		}
+
+		role := "taker"
+		if trade.Make {
+			role = "maker"
+		}
+
+		writer.Write([]string{
+			fmt.Sprint(trade.Id),
+			e.date(trade.Date),
+			base,
+			quote,
+			role,
+			trade.FeeValue,
+			quote,
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteRealisedGains writes one row per closed tax lot. Cost and proceeds
+// include the fees of the opening and closing trade. Sells of holdings
+// bought before the given trades are written with cost_known false and
+// without open trade, cost and gain.
+func (e *TradeExporter) WriteRealisedGains(w io.Writer, trades []*TradeResponse) error {
+	calculator, err := NewPnLCalculator(e.CostBasis)
+	if err != nil {
+		return err
+	}
+
+	fills, err := calculator.AddTrades(trades)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"open_trade_id", "open_date", "close_trade_id", "close_date", "base_asset", "quote_asset", "amount", "cost", "proceeds", "gain", "cost_known"})
+
+	for _, fill := range fills {
+		base, quote, err := e.assets(fill.Trade.TradingPairId)
+		if err != nil {
+			return err
+		}
+
+		for _, lot := range fill.ClosedLots {
+			openTradeId, openDate := fmt.Sprint(lot.OpenTradeId), e.date(lot.OpenDate)
+			cost, gain := formatPrecision(lot.Cost, quote), formatPrecision(lot.Gain, quote)
+			if lot.CostUnknown {
+				openTradeId, openDate, cost, gain = "", "", "", ""
+			}
+
+			writer.Write([]string{
+				openTradeId,
+				openDate,
+				fmt.Sprint(lot.CloseTradeId),
+				e.date(lot.CloseDate),
+				base.IsoCode,
+				quote.IsoCode,
+				formatPrecision(lot.Amount, base),
+				cost,
+				formatPrecision(lot.Proceeds, quote),
+				gain,
+				fmt.Sprint(!lot.CostUnknown),
+			})
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (e *TradeExporter) date(millis int64) string {
+	location := e.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	return time.UnixMilli(millis).In(location).Format(time.RFC3339)
+}
+
+func (e *TradeExporter) assetCodes(tradingPairId int64) (string, string, error) {
+	base, quote, err := e.assets(tradingPairId)
+	if err != nil {
+		return "", "", err
+	}
+
+	return base.IsoCode, quote.IsoCode, nil
+}
+
+func (e *TradeExporter) assets(tradingPairId int64) (*TradingAsset, *TradingAsset, error) {
+	pair, err := e.client.TradingPairFromId(tradingPairId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	base, err := e.client.TradingAssetFromId(pair.BaseAssetId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quote, err := e.client.TradingAssetFromId(pair.QuoteAssetId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return base, quote, nil
+}
+
+// formatPrecision writes f rounded to the asset's decimal precision, or as
+// is if the asset has none.
+func formatPrecision(f float64, asset *TradingAsset) string {
+	precision, ok := asset.Precision()
+	if !ok {
+		return formatFloat(f)
+	}
+
+	return strconv.FormatFloat(f, 'f', int(precision), 64)
+}
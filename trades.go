@@ -1,5 +1,15 @@
 package blocktrade
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PUBLIC_TRADES_ENDPOINT and PublicTradeResponse are not covered by the API
+// documentation this client is built from and are unverified.
+const PUBLIC_TRADES_ENDPOINT = "/public_trades"
+
 type TradeResponse struct {
 	Id            int64     `json:"id"`
 	OrderId       int64     `json:"order_id"`
@@ -13,3 +23,27 @@ type TradeResponse struct {
 	TradeValue    string    `json:"trade_value"`
 	Make          bool      `json:"maker"`
 }
+
+// PublicTradeResponse is a trade on the public tape. Its fields match the
+// ones of TradeResponse, Direction is the taker's side.
+type PublicTradeResponse struct {
+	Id            int64     `json:"id"`
+	TradingPairId int64     `json:"trading_pair_id"`
+	Direction     Direction `json:"direction"`
+	Amount        string    `json:"amount"`
+	Price         string    `json:"price"`
+	Date          int64     `json:"date"`
+}
+
+// GetPublicTrades returns the most recent public trades of a trading pair.
+func (a *APIClient) GetPublicTrades(ctx context.Context, tradingPairId int64) ([]*PublicTradeResponse, error) {
+	url := fmt.Sprintf("%v/%d", PUBLIC_TRADES_ENDPOINT, tradingPairId)
+	b, err := a.requestPublicGETContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*PublicTradeResponse, 0)
+	err = json.Unmarshal(b, &resp)
+	return resp, err
+}
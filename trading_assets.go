@@ -1,6 +1,7 @@
 package blocktrade
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -33,10 +34,43 @@ type TradingAsset struct {
 	DecimalPrecision        int64           `json:"decimal_precision"`
 	LotSize                 string          `json:"lot_size"`
 	DepositMethods          []DepositMethod `json:"deposit_methods"`
+
+	hasDecimalPrecision bool
+}
+
+// UnmarshalJSON decodes the asset and records whether decimal_precision was
+// present, as zero decimals is a valid precision.
+func (t *TradingAsset) UnmarshalJSON(b []byte) error {
+	type tradingAsset TradingAsset
+	aux := struct {
+		*tradingAsset
+		DecimalPrecision *int64 `json:"decimal_precision"`
+	}{tradingAsset: (*tradingAsset)(t)}
+
+	err := json.Unmarshal(b, &aux)
+	if err != nil {
+		return err
+	}
+
+	t.DecimalPrecision, t.hasDecimalPrecision = 0, aux.DecimalPrecision != nil
+	if t.hasDecimalPrecision {
+		t.DecimalPrecision = *aux.DecimalPrecision
+	}
+
+	return nil
+}
+
+// Precision returns the asset's number of decimals and whether it is known.
+func (t *TradingAsset) Precision() (int64, bool) {
+	return t.DecimalPrecision, t.hasDecimalPrecision
 }
 
 func (a *APIClient) TradingAssets() ([]*TradingAsset, error) {
-	b, err := a.requestPublicGET(TRADING_ASSETS_ENDPOINT)
+	return a.tradingAssets(context.Background())
+}
+
+func (a *APIClient) tradingAssets(ctx context.Context) ([]*TradingAsset, error) {
+	b, err := a.requestPublicGETContext(ctx, TRADING_ASSETS_ENDPOINT)
 	if err != nil {
 		return nil, err
 	}
@@ -47,49 +81,63 @@ func (a *APIClient) TradingAssets() ([]*TradingAsset, error) {
 }
 
 func (a *APIClient) TradingAssetFromId(id int64) (*TradingAsset, error) {
-	if val, ok := a.assetCache[id]; ok {
+	if val, ok := a.cachedAssetFromId(id); ok {
 		return val, nil
 	}
 
+	missKey := fmt.Sprintf("asset id %d", id)
+	if ok, err := a.metadata.knownMiss(missKey); ok {
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("asset not found for id %d", id)
+	}
+
 	// not in cache. refetching
-	assets, err := a.TradingAssets()
+	err := a.refreshAssets(context.Background())
 	if err != nil {
+		a.metadata.recordMiss(missKey, err)
 		return nil, err
 	}
 
-	for _, asset := range assets {
-		a.assetCache[asset.Id] = asset
-	}
-
-	if val, ok := a.assetCache[id]; ok {
+	if val, ok := a.cachedAssetFromId(id); ok {
 		return val, nil
-	} else {
-		return nil, fmt.Errorf("asset not found for id %d", id)
 	}
+
+	a.metadata.recordMiss(missKey, nil)
+	return nil, fmt.Errorf("asset not found for id %d", id)
 }
 
 func (a *APIClient) TradingAssetFromCode(isoCode string) (*TradingAsset, error) {
-	for _, asset := range a.assetCache {
-		if asset.IsoCode == isoCode {
-			return asset, nil
+	match := func(asset *TradingAsset) bool {
+		return asset.IsoCode == isoCode
+	}
+
+	if val, ok := a.cachedAsset(match); ok {
+		return val, nil
+	}
+
+	missKey := fmt.Sprintf("asset code %v", isoCode)
+	if ok, err := a.metadata.knownMiss(missKey); ok {
+		if err != nil {
+			return nil, err
 		}
+
+		return nil, fmt.Errorf("asset not found for iso code: %v", isoCode)
 	}
 
 	// not in cache. refetching
-	assets, err := a.TradingAssets()
+	err := a.refreshAssets(context.Background())
 	if err != nil {
+		a.metadata.recordMiss(missKey, err)
 		return nil, err
 	}
 
-	for _, asset := range assets {
-		a.assetCache[asset.Id] = asset
-	}
-
-	for _, asset := range a.assetCache {
-		if asset.IsoCode == isoCode {
-			return asset, nil
-		}
+	if val, ok := a.cachedAsset(match); ok {
+		return val, nil
 	}
 
+	a.metadata.recordMiss(missKey, nil)
 	return nil, fmt.Errorf("asset not found for iso code: %v", isoCode)
 }
@@ -1,6 +1,7 @@
 package blocktrade
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -17,7 +18,11 @@ type TradingPair struct {
 }
 
 func (a *APIClient) TradingPairs() ([]*TradingPair, error) {
-	b, err := a.requestPublicGET(TRADING_PAIRS_ENDPOINT)
+	return a.tradingPairs(context.Background())
+}
+
+func (a *APIClient) tradingPairs(ctx context.Context) ([]*TradingPair, error) {
+	b, err := a.requestPublicGETContext(ctx, TRADING_PAIRS_ENDPOINT)
 	if err != nil {
 		return nil, err
 	}
@@ -28,49 +33,78 @@ func (a *APIClient) TradingPairs() ([]*TradingPair, error) {
 }
 
 func (a *APIClient) TradingPairFromId(id int64) (*TradingPair, error) {
-	if val, ok := a.pairCache[id]; ok {
+	if val, ok := a.cachedPairFromId(id); ok {
 		return val, nil
 	}
 
+	missKey := fmt.Sprintf("pair id %d", id)
+	if ok, err := a.metadata.knownMiss(missKey); ok {
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("pair not found for id %d", id)
+	}
+
 	// not in cache. refetching
-	pairs, err := a.TradingPairs()
+	err := a.refreshPairs(context.Background())
 	if err != nil {
+		a.metadata.recordMiss(missKey, err)
 		return nil, err
 	}
 
-	for _, pair := range pairs {
-		a.pairCache[pair.Id] = pair
-	}
-
-	if val, ok := a.pairCache[id]; ok {
+	if val, ok := a.cachedPairFromId(id); ok {
 		return val, nil
-	} else {
-		return nil, fmt.Errorf("pair not found for id %d", id)
 	}
+
+	a.metadata.recordMiss(missKey, nil)
+	return nil, fmt.Errorf("pair not found for id %d", id)
 }
 
 func (a *APIClient) TradingPairFromBaseQuote(baseId int64, quoteId int64) (*TradingPair, error) {
-	for _, pair := range a.pairCache {
-		if pair.BaseAssetId == baseId && pair.QuoteAssetId == quoteId {
-			return pair, nil
+	match := func(pair *TradingPair) bool {
+		return pair.BaseAssetId == baseId && pair.QuoteAssetId == quoteId
+	}
+
+	if val, ok := a.cachedPair(match); ok {
+		return val, nil
+	}
+
+	missKey := fmt.Sprintf("pair %d/%d", baseId, quoteId)
+	if ok, err := a.metadata.knownMiss(missKey); ok {
+		if err != nil {
+			return nil, err
 		}
+
+		return nil, fmt.Errorf("pair not founf for base %d and quote %d", baseId, quoteId)
 	}
 
 	// not in cache. refetching
-	pairs, err := a.TradingPairs()
+	err := a.refreshPairs(context.Background())
 	if err != nil {
+		a.metadata.recordMiss(missKey, err)
 		return nil, err
 	}
 
-	for _, pair := range pairs {
-		a.pairCache[pair.Id] = pair
+	if val, ok := a.cachedPair(match); ok {
+		return val, nil
 	}
 
-	for _, pair := range a.pairCache {
-		if pair.BaseAssetId == baseId && pair.QuoteAssetId == quoteId {
-			return pair, nil
-		}
+	a.metadata.recordMiss(missKey, nil)
+	return nil, fmt.Errorf("pair not founf for base %d and quote %d", baseId, quoteId)
+}
+
+// pairSymbol returns the symbol of pair in BASE/QUOTE form, e.g. BTC/EUR.
+func (a *APIClient) pairSymbol(pair *TradingPair) (string, error) {
+	base, err := a.TradingAssetFromId(pair.BaseAssetId)
+	if err != nil {
+		return "", err
 	}
 
-	return nil, fmt.Errorf("pair not founf for base %d and quote %d", baseId, quoteId)
+	quote, err := a.TradingAssetFromId(pair.QuoteAssetId)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%v/%v", base.IsoCode, quote.IsoCode), nil
 }
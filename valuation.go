@@ -0,0 +1,263 @@
+package blocktrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PriceLeg is one conversion step of a price route. An inverse leg converts
+// from the pair's quote to its base asset.
+type PriceLeg struct {
+	TradingPairId int64
+	Inverse       bool
+	Price         float64
+}
+
+// AssetValuation is a balance valued in the valuation currency. Route is
+// empty for the valuation currency itself. Unpriced balances have no route
+// between their asset and the valuation currency, or no price for it, and a
+// zero Value. PriceErr is why a ticker of the route could not be used.
+type AssetValuation struct {
+	Balance  *Balance
+	Price    float64
+	Value    float64
+	Route    []PriceLeg
+	Source   string
+	Unpriced bool
+	PriceErr error
+}
+
+type PortfolioValuation struct {
+	PortfolioId int64
+	Currency    *TradingAsset
+	Assets      []*AssetValuation
+	Total       float64
+}
+
+// ValuePortfolio values the default portfolio in the user's primary
+// currency.
+func (a *APIClient) ValuePortfolio(ctx context.Context) (*PortfolioValuation, error) {
+	portfolioId, err := a.GetPortfolioId()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.User()
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PrimaryCurrency == nil {
+		return nil, fmt.Errorf("user has no primary currency")
+	}
+
+	return a.ValuePortfolioIn(ctx, portfolioId, user.PrimaryCurrency.Id)
+}
+
+// ValuePortfolioIn values every balance of the portfolio in the currency
+// asset using the last price of a direct or inverse pair, or of a two pair
+// route through an intermediate asset. Balances whose ticker fails are
+// marked Unpriced and left out of the Total.
+func (a *APIClient) ValuePortfolioIn(ctx context.Context, portfolioId int64, currencyAssetId int64) (*PortfolioValuation, error) {
+	currency, err := a.TradingAssetFromId(currencyAssetId)
+	if err != nil {
+		return nil, err
+	}
+
+	balances, err := a.PortfolioBalances(ctx, portfolioId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, err := a.cachedPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation := &PortfolioValuation{
+		PortfolioId: portfolioId,
+		Currency:    currency,
+		Assets:      make([]*AssetValuation, 0, len(balances)),
+	}
+
+	routes := make(map[int64][]PriceLeg)
+	pairIds := make([]int64, 0)
+	seen := make(map[int64]bool)
+	for _, balance := range balances {
+		route, ok := priceRoute(pairs, balance.Asset.Id, currencyAssetId)
+		if !ok {
+			continue
+		}
+
+		routes[balance.Asset.Id] = route
+		for _, leg := range route {
+			if !seen[leg.TradingPairId] {
+				seen[leg.TradingPairId] = true
+				pairIds = append(pairIds, leg.TradingPairId)
+			}
+		}
+	}
+
+	prices := make(map[int64]float64)
+	priceErrs := make(map[int64]error)
+	if len(pairIds) > 0 {
+		tickers, errs := a.fetchTickers(ctx, pairIds, false)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		for i, ticker := range tickers {
+			if errs[i] != nil {
+				priceErrs[pairIds[i]] = fmt.Errorf("ticker for pair %d: %w", pairIds[i], errs[i])
+				continue
+			}
+
+			prices[ticker.TradingPairId], err = tickerPrice(&ticker.Data)
+			if err != nil {
+				priceErrs[ticker.TradingPairId] = fmt.Errorf("ticker for pair %d: %w", ticker.TradingPairId, err)
+			}
+		}
+	}
+
+	for _, balance := range balances {
+		assetValuation := &AssetValuation{
+			Balance: balance,
+		}
+		valuation.Assets = append(valuation.Assets, assetValuation)
+
+		route, ok := routes[balance.Asset.Id]
+		if !ok {
+			assetValuation.Unpriced = true
+			continue
+		}
+
+		price := 1.0
+		for i := range route {
+			legPrice := prices[route[i].TradingPairId]
+			if legPrice == 0 {
+				assetValuation.Unpriced = true
+				assetValuation.PriceErr = priceErrs[route[i].TradingPairId]
+				break
+			}
+
+			if route[i].Inverse {
+				legPrice = 1 / legPrice
+			}
+
+			route[i].Price = legPrice
+			price *= legPrice
+		}
+
+		if assetValuation.Unpriced {
+			continue
+		}
+
+		assetValuation.Route = route
+		assetValuation.Source, err = a.routeSource(route)
+		if err != nil {
+			return nil, err
+		}
+
+		assetValuation.Price = price
+		assetValuation.Value = balance.Total * price
+		valuation.Total += assetValuation.Value
+	}
+
+	return valuation, nil
+}
+
+// tickerPrice returns the last price, or the mid price if there was no
+// trade yet.
+func tickerPrice(ticker *TickerData) (float64, error) {
+	last, err := parseOptionalFloat(ticker.LastPrice)
+	if err != nil || last > 0 {
+		return last, err
+	}
+
+	bid, err := parseOptionalFloat(ticker.BidPrice)
+	if err != nil {
+		return 0, err
+	}
+
+	ask, err := parseOptionalFloat(ticker.AskPrice)
+	if err != nil {
+		return 0, err
+	}
+
+	if bid == 0 || ask == 0 {
+		return 0, nil
+	}
+
+	return (bid + ask) / 2, nil
+}
+
+// priceRoute finds the legs converting from into to, preferring a single
+// pair over two pairs through an intermediate asset.
+func priceRoute(pairs []*TradingPair, from, to int64) ([]PriceLeg, bool) {
+	if from == to {
+		return []PriceLeg{}, true
+	}
+
+	if leg, ok := priceLeg(pairs, from, to); ok {
+		return []PriceLeg{leg}, true
+	}
+
+	for _, pair := range pairs {
+		var intermediate int64
+		switch from {
+		case pair.BaseAssetId:
+			intermediate = pair.QuoteAssetId
+		case pair.QuoteAssetId:
+			intermediate = pair.BaseAssetId
+		default:
+			continue
+		}
+
+		first, _ := priceLeg(pairs, from, intermediate)
+		if second, ok := priceLeg(pairs, intermediate, to); ok {
+			return []PriceLeg{first, second}, true
+		}
+	}
+
+	return nil, false
+}
+
+func priceLeg(pairs []*TradingPair, from, to int64) (PriceLeg, bool) {
+	for _, pair := range pairs {
+		if pair.BaseAssetId == from && pair.QuoteAssetId == to {
+			return PriceLeg{TradingPairId: pair.Id}, true
+		}
+	}
+
+	for _, pair := range pairs {
+		if pair.BaseAssetId == to && pair.QuoteAssetId == from {
+			return PriceLeg{TradingPairId: pair.Id, Inverse: true}, true
+		}
+	}
+
+	return PriceLeg{}, false
+}
+
+// routeSource describes route, e.g. "ETH/BTC, BTC/EUR" or "1/(EUR/USDT)".
+func (a *APIClient) routeSource(route []PriceLeg) (string, error) {
+	if len(route) == 0 {
+		return "identity", nil
+	}
+
+	parts := make([]string, 0, len(route))
+	for _, leg := range route {
+		symbol, err := NewSymbolResolver(a).SymbolFromId(leg.TradingPairId)
+		if err != nil {
+			return "", err
+		}
+
+		if leg.Inverse {
+			symbol = fmt.Sprintf("1/(%v)", symbol)
+		}
+
+		parts = append(parts, symbol)
+	}
+
+	return strings.Join(parts, ", "), nil
+}
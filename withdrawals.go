@@ -0,0 +1,204 @@
+package blocktrade
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// WITHDRAWALS_ENDPOINT and the withdrawal schemas below are not covered by
+// the API documentation this client is built from and are unverified.
+const WITHDRAWALS_ENDPOINT = "/withdrawals"
+
+var ErrInvalidWithdrawalAmount = errors.New("withdrawal amount must be positive")
+var ErrBelowMinimalWithdrawalAmount = errors.New("amount below minimal withdrawal amount")
+var ErrMissingWithdrawalDestination = errors.New("missing withdrawal destination")
+var ErrMissingWithdrawalBeneficiary = errors.New("missing withdrawal beneficiary")
+
+type WithdrawalStatus string
+
+const WithdrawalStatus_PENDING WithdrawalStatus = "PENDING"
+const WithdrawalStatus_PROCESSING WithdrawalStatus = "PROCESSING"
+const WithdrawalStatus_SENT WithdrawalStatus = "SENT"
+const WithdrawalStatus_COMPLETED WithdrawalStatus = "COMPLETED"
+const WithdrawalStatus_REJECTED WithdrawalStatus = "REJECTED"
+const WithdrawalStatus_CANCELLED WithdrawalStatus = "CANCELLED"
+
+// Final reports whether the withdrawal will not change status anymore.
+func (s WithdrawalStatus) Final() bool {
+	return s == WithdrawalStatus_COMPLETED || s == WithdrawalStatus_REJECTED || s == WithdrawalStatus_CANCELLED
+}
+
+// WithdrawalRequest withdraws Amount of an asset. Crypto withdrawals need an
+// Address and, for some assets, a Tag. Bank withdrawals need an Iban and the
+// BeneficiaryName, outside SEPA also the Bic.
+type WithdrawalRequest struct {
+	PortfolioId     int64            `json:"portfolio_id"`
+	TradingAssetId  int64            `json:"trading_asset_id"`
+	Method          WithdrawalMethod `json:"method"`
+	Amount          string           `json:"amount"`
+	Address         string           `json:"address,omitempty"`
+	Tag             string           `json:"tag,omitempty"`
+	Iban            string           `json:"iban,omitempty"`
+	Bic             string           `json:"bic,omitempty"`
+	BeneficiaryName string           `json:"beneficiary_name,omitempty"`
+	Reference       string           `json:"reference,omitempty"`
+}
+
+type WithdrawalResponse struct {
+	Id             int64            `json:"id"`
+	PortfolioId    int64            `json:"portfolio_id"`
+	TradingAssetId int64            `json:"trading_asset_id"`
+	Method         WithdrawalMethod `json:"method"`
+	Amount         string           `json:"amount"`
+	Fee            string           `json:"fee"`
+	Address        string           `json:"address"`
+	Tag            string           `json:"tag"`
+	Iban           string           `json:"iban"`
+	TransactionId  string           `json:"transaction_id"`
+	Status         WithdrawalStatus `json:"status"`
+	Date           int64            `json:"date"`
+}
+
+// WithdrawalPreview is the expected outcome of a withdrawal. Received is
+// Amount minus Fee, all in the withdrawn asset.
+type WithdrawalPreview struct {
+	Amount        float64
+	Fee           float64
+	Received      float64
+	MinimalAmount float64
+
+	// Problems lists why the withdrawal would likely be rejected.
+	Problems []error
+}
+
+// Err returns the first problem with the preview, if any.
+func (p *WithdrawalPreview) Err() error {
+	if len(p.Problems) == 0 {
+		return nil
+	}
+
+	return p.Problems[0]
+}
+
+// PreviewWithdrawal checks request against the asset's minimal withdrawal
+// amount and computes the fee from the fee schedule. A fee missing from the
+// schedule is reported as ErrUnknownFee in Problems.
+func (a *APIClient) PreviewWithdrawal(ctx context.Context, request *WithdrawalRequest) (*WithdrawalPreview, error) {
+	asset, err := a.TradingAssetFromId(request.TradingAssetId)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := parseOptionalFloat(request.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	minAmount, err := parseOptionalFloat(asset.MinimalWithdrawalAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := a.FeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &WithdrawalPreview{
+		Amount:        amount,
+		MinimalAmount: minAmount,
+	}
+
+	if amount <= 0 {
+		preview.Problems = append(preview.Problems, ErrInvalidWithdrawalAmount)
+	} else if amount < minAmount {
+		preview.Problems = append(preview.Problems, ErrBelowMinimalWithdrawalAmount)
+	}
+
+	preview.Fee, err = schedule.WithdrawalFee(request.Method, asset.IsoCode, amount)
+	if err == ErrUnknownFee {
+		preview.Problems = append(preview.Problems, ErrUnknownFee)
+	} else if err != nil {
+		return nil, err
+	}
+	preview.Received = amount - preview.Fee
+
+	if request.Method == WithdrawalMethod_CRYPTO {
+		if request.Address == "" {
+			preview.Problems = append(preview.Problems, ErrMissingWithdrawalDestination)
+		}
+	} else {
+		if request.Iban == "" || (request.Method == WithdrawalMethod_OUTSIDE_SEPA && request.Bic == "") {
+			preview.Problems = append(preview.Problems, ErrMissingWithdrawalDestination)
+		}
+
+		if request.BeneficiaryName == "" {
+			preview.Problems = append(preview.Problems, ErrMissingWithdrawalBeneficiary)
+		}
+	}
+
+	return preview, nil
+}
+
+// CreateWithdrawal validates and submits a withdrawal. Withdrawals without
+// PortfolioId are made from the default portfolio. The request is rejected
+// with the preview's problems, except ErrUnknownFee as the exchange charges
+// its fee regardless.
+func (a *APIClient) CreateWithdrawal(ctx context.Context, request *WithdrawalRequest) (*WithdrawalResponse, error) {
+	preview, err := a.PreviewWithdrawal(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, problem := range preview.Problems {
+		if problem != ErrUnknownFee {
+			return nil, problem
+		}
+	}
+
+	if request.PortfolioId == 0 {
+		portfolioId, err := a.GetPortfolioId()
+		if err != nil {
+			return nil, err
+		}
+
+		withPortfolio := *request
+		withPortfolio.PortfolioId = portfolioId
+		request = &withPortfolio
+	}
+
+	b, err := a.requestPOSTContext(ctx, WITHDRAWALS_ENDPOINT, request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(WithdrawalResponse)
+	err = json.Unmarshal(b, &resp)
+	return resp, err
+}
+
+func (a *APIClient) GetWithdrawal(ctx context.Context, id int64) (*WithdrawalResponse, error) {
+	url := fmt.Sprintf("%v/%v", WITHDRAWALS_ENDPOINT, id)
+	b, err := a.requestGETContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(WithdrawalResponse)
+	err = json.Unmarshal(b, &resp)
+	return resp, err
+}
+
+// Withdrawals lists the withdrawals of the account.
+func (a *APIClient) Withdrawals(ctx context.Context) ([]*WithdrawalResponse, error) {
+	b, err := a.requestGETContext(ctx, WITHDRAWALS_ENDPOINT)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*WithdrawalResponse, 0)
+	err = json.Unmarshal(b, &resp)
+	return resp, err
+}
@@ -3,14 +3,16 @@ package blocktrade
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"log"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-const WS_URL = "wss://trade.blocktrade.com/ws/v1/notification"
+// WS_URL is a variable so that it can be pointed at another server, e.g. in
+// tests.
+var WS_URL = "wss://trade.blocktrade.com/ws/v1/notification"
+
 const MESSAGE_BUFFER_SIZE = 10
 
 type MessageType string
@@ -18,10 +20,21 @@ type MessageType string
 const MessageType_UserOrders MessageType = "user_orders"
 const MessageType_UserTrades MessageType = "user_trades"
 const MessageType_Ticker MessageType = "ticker"
+const MessageType_PublicTrades MessageType = "public_trades"
 
 type UserOrderHandlerFunc func(orderResponse *OrderResponse, err error)
 type UserTradeHandlerFunc func(tradeResponse *TradeResponse, err error)
 type TickerHandlerFunc func(TickerResponse *TickerResponse, err error)
+type PublicTradeHandlerFunc func(tradeResponse *PublicTradeResponse, err error)
+
+// RawMessageHandlerFunc receives every message read from the websocket
+// before it is dispatched to the typed handlers. messageType is empty if the
+// message could not be decoded.
+type RawMessageHandlerFunc func(messageType MessageType, message []byte)
+
+// UnknownMessageHandlerFunc receives messages whose message_type has no
+// typed handler in this package, e.g. acknowledgements or server errors.
+type UnknownMessageHandlerFunc func(messageType MessageType, message []byte)
 
 type blocktradeWebsocketMessage struct {
 	MessageType MessageType            `json:"message_type"`
@@ -36,30 +49,67 @@ type blockTradeUserTradesWsRespones struct {
 	Data []*TradeResponse `json:"data"`
 }
 
+type blockTradePublicTradesWsResponse struct {
+	Data []*PublicTradeResponse `json:"data"`
+}
+
+type publicTradesSubscription struct {
+	f PublicTradeHandlerFunc
+}
+
 type websocketMessage struct {
 	Message []byte
 	Error   error
 }
 
+// Websocket connects to the notification websocket, replacing any previous
+// connection, and starts pinging it. The returned channel receives the error
+// that ended the connection and is closed afterwards. A connection that stays
+// silent longer than the pong timeout ends with ErrWebsocketStale, which
+// callers should treat as a signal to reconnect. Like Close, it must not be
+// called from a websocket handler.
 func (a *APIClient) Websocket() (<-chan error, error) {
+	// held from closing the previous connection until the new one is
+	// installed, so that concurrent calls cannot leave a session behind
+	a.wsConnMtx.Lock()
+	defer a.wsConnMtx.Unlock()
+
+	a.closeWs()
+
 	wsChan := make(chan websocketMessage, MESSAGE_BUFFER_SIZE)
-	wsCloseChan := make(chan error)
+	wsCloseChan := make(chan error, 1)
 
 	conn, _, err := websocket.DefaultDialer.Dial(WS_URL, nil)
 	if err != nil {
 		return nil, err
 	}
-	a.wsConn = conn
-	go a.receiveWsMessages(a.wsConn, wsChan)
-	go a.handleWsMessages(wsChan, wsCloseChan)
+
+	a.wsMtx.Lock()
+	ws := newWsSession(conn, a.wsPongTimeout)
+	pingInterval := a.wsPingInterval
+	a.ws = ws
+	a.wsMtx.Unlock()
+
+	ws.goroutine(ws.writeLoop)
+	ws.goroutine(func() { a.receiveWsMessages(ws, wsChan) })
+	ws.goroutine(func() { a.handleWsMessages(ws, wsChan, wsCloseChan) })
+
+	if pingInterval > 0 {
+		err = a.StartPing(pingInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return wsCloseChan, nil
 }
 
-func (a *APIClient) handleWsMessages(wsChan chan websocketMessage, wsCloseChan chan error) {
+func (a *APIClient) handleWsMessages(ws *wsSession, wsChan chan websocketMessage, wsCloseChan chan error) {
 	for {
 		msg := <-wsChan
 		if msg.Error != nil {
+			ws.shutdown()
+			a.failWsAckWaiters(msg.Error)
 			wsCloseChan <- msg.Error
 			close(wsCloseChan)
 			return
@@ -69,14 +119,29 @@ func (a *APIClient) handleWsMessages(wsChan chan websocketMessage, wsCloseChan c
 			log.Printf("WS EVENT: %v\n", string(msg.Message))
 		}
 
+		// the raw hook sees the message even if the envelope does not
+		// decode, with an empty message type
 		wsMsg := new(blocktradeWebsocketMessage)
 		err := json.Unmarshal(msg.Message, &wsMsg)
+		if err != nil {
+			wsMsg = new(blocktradeWebsocketMessage)
+		}
+
+		a.wsHandlerMtx.Lock()
+		if a.wsRawHandler != nil {
+			a.wsRawHandler(wsMsg.MessageType, msg.Message)
+		}
+		a.wsHandlerMtx.Unlock()
+
 		if err != nil {
 			log.Printf("WS ERROR: %v\n", err)
 			continue
 		}
 
+		acked := a.resolveWsAck(wsMsg)
+
 		a.wsHandlerMtx.Lock()
+
 		switch wsMsg.MessageType {
 		case MessageType_UserOrders:
 			var f UserOrderHandlerFunc
@@ -150,16 +215,29 @@ func (a *APIClient) handleWsMessages(wsChan chan websocketMessage, wsCloseChan c
 			}
 			f(tickerResponse, nil)
 
+		case MessageType_PublicTrades:
+			a.handlePublicTradesMessage(wsMsg.Payload)
+
+		case MessageType_OrderBook:
+			a.handleOrderBookMessage(ws, wsMsg.Payload)
+
 		default:
-			log.Printf("Unhandled message_type: %v\n", wsMsg.MessageType)
+			if a.wsUnknownHandler != nil {
+				a.wsUnknownHandler(wsMsg.MessageType, msg.Message)
+				break
+			}
+
+			if !acked {
+				log.Printf("Unhandled message_type: %v\n", wsMsg.MessageType)
+			}
 		}
 		a.wsHandlerMtx.Unlock()
 	}
 }
 
-func (a *APIClient) receiveWsMessages(conn *websocket.Conn, wsChan chan websocketMessage) {
+func (a *APIClient) receiveWsMessages(ws *wsSession, wsChan chan websocketMessage) {
 	for {
-		t, msg, err := conn.ReadMessage()
+		t, msg, err := ws.read()
 		if err != nil {
 			wsChan <- websocketMessage{Error: err}
 			close(wsChan)
@@ -174,40 +252,94 @@ func (a *APIClient) receiveWsMessages(conn *websocket.Conn, wsChan chan websocke
 	}
 }
 
-func (a *APIClient) SubscribeUserOrders(f UserOrderHandlerFunc) error {
-	if a.wsConn == nil {
-		return errors.New("websocket not initialized")
-	}
+// OnRawMessage registers f to be called with every websocket message.
+// Passing nil removes the hook.
+func (a *APIClient) OnRawMessage(f RawMessageHandlerFunc) {
+	a.wsHandlerMtx.Lock()
+	a.wsRawHandler = f
+	a.wsHandlerMtx.Unlock()
+}
 
+// OnUnknownMessage registers f to be called for messages with an unhandled
+// message_type instead of logging them. Passing nil removes the hook.
+func (a *APIClient) OnUnknownMessage(f UnknownMessageHandlerFunc) {
 	a.wsHandlerMtx.Lock()
-	a.wsHandlers[MessageType_UserOrders] = f
+	a.wsUnknownHandler = f
 	a.wsHandlerMtx.Unlock()
+}
 
-	userResp, err := a.User()
+// setWsHandler registers f for messages of type t. The returned function
+// puts back the previous handler, for when the subscription fails.
+func (a *APIClient) setWsHandler(t MessageType, f interface{}) func() {
+	a.wsHandlerMtx.Lock()
+	defer a.wsHandlerMtx.Unlock()
+
+	prev, ok := a.wsHandlers[t]
+	a.wsHandlers[t] = f
+
+	return func() {
+		a.wsHandlerMtx.Lock()
+		defer a.wsHandlerMtx.Unlock()
+
+		if ok {
+			a.wsHandlers[t] = prev
+		} else {
+			delete(a.wsHandlers, t)
+		}
+	}
+}
+
+func (a *APIClient) SubscribeUserOrders(f UserOrderHandlerFunc) error {
+	params, restore, err := a.subscribeUserOrdersParams(f)
 	if err != nil {
 		return err
 	}
 
-	subscribeMessage := map[string]interface{}{
-		"subscribe_user_orders": map[string]interface{}{
-			"auth_token": userResp.WebsocketAuthToken,
-		},
+	err = a.writeWsCommand("subscribe_user_orders", params)
+	if err != nil {
+		restore()
 	}
 
-	err = a.wsConn.WriteJSON(subscribeMessage)
 	return err
 }
 
-func (a *APIClient) UnsubscribeUserOrders() error {
-	if a.wsConn == nil {
-		return errors.New("websocket not initialized")
+// SubscribeUserOrdersContext is like SubscribeUserOrders but waits for the
+// server to acknowledge the subscription.
+func (a *APIClient) SubscribeUserOrdersContext(ctx context.Context, f UserOrderHandlerFunc) error {
+	params, restore, err := a.subscribeUserOrdersParams(f)
+	if err != nil {
+		return err
+	}
+
+	err = a.writeWsCommandAck(ctx, "subscribe_user_orders", params)
+	if err != nil {
+		restore()
+	}
+
+	return err
+}
+
+func (a *APIClient) subscribeUserOrdersParams(f UserOrderHandlerFunc) (map[string]interface{}, func(), error) {
+	if a.currentWs() == nil {
+		return nil, nil, ErrWebsocketNotInitialized
 	}
 
-	unsubcribeMessage := map[string]interface{}{
-		"unsubscribe_user_orders": map[string]interface{}{},
+	userResp, err := a.User()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	err := a.wsConn.WriteJSON(unsubcribeMessage)
+	params := map[string]interface{}{
+		"auth_token": userResp.WebsocketAuthToken,
+	}
+
+	restore := a.setWsHandler(MessageType_UserOrders, f)
+
+	return params, restore, nil
+}
+
+func (a *APIClient) UnsubscribeUserOrders() error {
+	err := a.writeWsCommand("unsubscribe_user_orders", map[string]interface{}{})
 	if err != nil {
 		return err
 	}
@@ -220,42 +352,59 @@ func (a *APIClient) UnsubscribeUserOrders() error {
 }
 
 func (a *APIClient) SubscribeUserTrades(replayTime time.Duration, f UserTradeHandlerFunc) error {
-	if a.wsConn == nil {
-		return errors.New("websocket not initialized")
+	params, restore, err := a.subscribeUserTradesParams(replayTime, f)
+	if err != nil {
+		return err
 	}
 
-	a.wsHandlerMtx.Lock()
-	a.wsHandlers[MessageType_UserTrades] = f
-	a.wsHandlerMtx.Unlock()
+	err = a.writeWsCommand("subscribe_user_trades", params)
+	if err != nil {
+		restore()
+	}
 
-	userResp, err := a.User()
+	return err
+}
+
+// SubscribeUserTradesContext is like SubscribeUserTrades but waits for the
+// server to acknowledge the subscription.
+func (a *APIClient) SubscribeUserTradesContext(ctx context.Context, replayTime time.Duration, f UserTradeHandlerFunc) error {
+	params, restore, err := a.subscribeUserTradesParams(replayTime, f)
 	if err != nil {
 		return err
 	}
 
-	startTime := time.Now().Add(-replayTime)
-
-	subscribeMessage := map[string]interface{}{
-		"subscribe_user_trades": map[string]interface{}{
-			"auth_token": userResp.WebsocketAuthToken,
-			"start_time": startTime.UTC().UnixMilli(),
-		},
+	err = a.writeWsCommandAck(ctx, "subscribe_user_trades", params)
+	if err != nil {
+		restore()
 	}
 
-	err = a.wsConn.WriteJSON(subscribeMessage)
 	return err
 }
 
-func (a *APIClient) UnsubscribeUserTrades() error {
-	if a.wsConn == nil {
-		return errors.New("websocket not initialized")
+func (a *APIClient) subscribeUserTradesParams(replayTime time.Duration, f UserTradeHandlerFunc) (map[string]interface{}, func(), error) {
+	if a.currentWs() == nil {
+		return nil, nil, ErrWebsocketNotInitialized
 	}
 
-	unsubcribeMessage := map[string]interface{}{
-		"unsubscribe_user_trades": map[string]interface{}{},
+	userResp, err := a.User()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	err := a.wsConn.WriteJSON(unsubcribeMessage)
+	startTime := time.Now().Add(-replayTime)
+
+	params := map[string]interface{}{
+		"auth_token": userResp.WebsocketAuthToken,
+		"start_time": startTime.UTC().UnixMilli(),
+	}
+
+	restore := a.setWsHandler(MessageType_UserTrades, f)
+
+	return params, restore, nil
+}
+
+func (a *APIClient) UnsubscribeUserTrades() error {
+	err := a.writeWsCommand("unsubscribe_user_trades", map[string]interface{}{})
 	if err != nil {
 		return err
 	}
@@ -268,70 +417,208 @@ func (a *APIClient) UnsubscribeUserTrades() error {
 }
 
 func (a *APIClient) SubscribeTicker(tradingPairId int64, f TickerHandlerFunc) error {
-	if a.wsConn == nil {
-		return errors.New("websocket not initialized")
+	params, restore, err := a.subscribeTickerParams(tradingPairId, f)
+	if err != nil {
+		return err
+	}
+
+	err = a.writeWsCommand("subscribe_ticker", params)
+	if err != nil {
+		restore()
+	}
+
+	return err
+}
+
+// SubscribeTickerContext is like SubscribeTicker but waits for the server
+// to acknowledge the subscription.
+func (a *APIClient) SubscribeTickerContext(ctx context.Context, tradingPairId int64, f TickerHandlerFunc) error {
+	params, restore, err := a.subscribeTickerParams(tradingPairId, f)
+	if err != nil {
+		return err
+	}
+
+	err = a.writeWsCommandAck(ctx, "subscribe_ticker", params)
+	if err != nil {
+		restore()
+	}
+
+	return err
+}
+
+func (a *APIClient) subscribeTickerParams(tradingPairId int64, f TickerHandlerFunc) (map[string]interface{}, func(), error) {
+	if a.currentWs() == nil {
+		return nil, nil, ErrWebsocketNotInitialized
+	}
+
+	params := map[string]interface{}{
+		"trading_pair_id": tradingPairId,
+	}
+
+	restore := a.setWsHandler(MessageType_Ticker, f)
+
+	return params, restore, nil
+}
+
+func (a *APIClient) UnsubscribeTicker(tradingPairId int64) error {
+	params := map[string]interface{}{
+		"trading_pair_id": tradingPairId,
+	}
+
+	err := a.writeWsCommand("unsubscribe_ticker", params)
+	if err != nil {
+		return err
 	}
 
 	a.wsHandlerMtx.Lock()
-	a.wsHandlers[MessageType_Ticker] = f
+	delete(a.wsHandlers, MessageType_Ticker)
 	a.wsHandlerMtx.Unlock()
 
-	subscribeMessage := map[string]interface{}{
-		"subscribe_ticker": map[string]interface{}{
-			"trading_pair_id": tradingPairId,
-		},
+	return nil
+}
+
+// SubscribePublicTrades calls f with the public trades of a trading pair,
+// replacing an earlier handler of the same pair. The public_trades channel
+// and its commands are not covered by the API documentation this client is
+// built from and are unverified.
+func (a *APIClient) SubscribePublicTrades(tradingPairId int64, f PublicTradeHandlerFunc) error {
+	params, restore, err := a.subscribePublicTradesParams(tradingPairId, f)
+	if err != nil {
+		return err
+	}
+
+	err = a.writeWsCommand("subscribe_public_trades", params)
+	if err != nil {
+		restore()
+	}
+
+	return err
+}
+
+// SubscribePublicTradesContext is like SubscribePublicTrades but waits for
+// the server to acknowledge the subscription.
+func (a *APIClient) SubscribePublicTradesContext(ctx context.Context, tradingPairId int64, f PublicTradeHandlerFunc) error {
+	params, restore, err := a.subscribePublicTradesParams(tradingPairId, f)
+	if err != nil {
+		return err
+	}
+
+	err = a.writeWsCommandAck(ctx, "subscribe_public_trades", params)
+	if err != nil {
+		restore()
 	}
 
-	err := a.wsConn.WriteJSON(subscribeMessage)
 	return err
 }
 
-func (a *APIClient) UnsubscribeTicker(tradingPairId int64) error {
-	if a.wsConn == nil {
-		return errors.New("websocket not initialized")
+func (a *APIClient) subscribePublicTradesParams(tradingPairId int64, f PublicTradeHandlerFunc) (map[string]interface{}, func(), error) {
+	if a.currentWs() == nil {
+		return nil, nil, ErrWebsocketNotInitialized
+	}
+
+	sub := &publicTradesSubscription{f: f}
+
+	a.wsHandlerMtx.Lock()
+	prev, ok := a.wsPublicTrades[tradingPairId]
+	a.wsPublicTrades[tradingPairId] = sub
+	a.wsHandlerMtx.Unlock()
+
+	restore := func() {
+		a.wsHandlerMtx.Lock()
+		defer a.wsHandlerMtx.Unlock()
+
+		if a.wsPublicTrades[tradingPairId] != sub {
+			return
+		}
+
+		if ok {
+			a.wsPublicTrades[tradingPairId] = prev
+		} else {
+			delete(a.wsPublicTrades, tradingPairId)
+		}
+	}
+
+	params := map[string]interface{}{
+		"trading_pair_id": tradingPairId,
 	}
 
-	unsubsribeMessage := map[string]interface{}{
-		"unsubscribe_tiker": map[string]interface{}{
-			"trading_pair_id": tradingPairId,
-		},
+	return params, restore, nil
+}
+
+// UnsubscribePublicTrades stops the public trades of a trading pair, the
+// subscriptions of other pairs are kept.
+func (a *APIClient) UnsubscribePublicTrades(tradingPairId int64) error {
+	params := map[string]interface{}{
+		"trading_pair_id": tradingPairId,
 	}
 
-	err := a.wsConn.WriteJSON(unsubsribeMessage)
+	err := a.writeWsCommand("unsubscribe_public_trades", params)
 	if err != nil {
 		return err
 	}
 
 	a.wsHandlerMtx.Lock()
-	delete(a.wsHandlers, MessageType_Ticker)
+	delete(a.wsPublicTrades, tradingPairId)
 	a.wsHandlerMtx.Unlock()
 
 	return nil
 }
 
+// handlePublicTradesMessage passes each trade to the handler of its pair. It
+// is called with wsHandlerMtx held.
+func (a *APIClient) handlePublicTradesMessage(payload map[string]interface{}) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	tradeResponse := new(blockTradePublicTradesWsResponse)
+	err = json.Unmarshal(b, &tradeResponse)
+	if err != nil {
+		for _, sub := range a.wsPublicTrades {
+			sub.f(nil, err)
+		}
+		return
+	}
+
+	for _, trade := range tradeResponse.Data {
+		if sub, ok := a.wsPublicTrades[trade.TradingPairId]; ok {
+			sub.f(trade, nil)
+		}
+	}
+}
+
 func (a *APIClient) StartPing(interval time.Duration) error {
-	if a.wsConn == nil {
-		return errors.New("websocket not initialized")
+	a.wsMtx.Lock()
+	defer a.wsMtx.Unlock()
+
+	ws := a.ws
+	if ws == nil {
+		return ErrWebsocketNotInitialized
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	if a.pingCancel != nil {
+		a.pingCancel()
+	}
 
-	a.pingCtx = ctx
+	ctx, cancel := context.WithCancel(context.Background())
 	a.pingCancel = cancel
 
-	go func() {
+	ws.goroutine(func() {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
 		for {
 			select {
-			case <-a.pingCtx.Done():
+			case <-ctx.Done():
+				return
+			case <-ws.done:
 				return
 			case <-ticker.C:
-				a.wsConn.WriteMessage(websocket.PingMessage, []byte{})
+				ws.writeMessage(websocket.PingMessage, []byte{})
 			}
 		}
-	}()
+	})
 
 	return nil
 }
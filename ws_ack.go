@@ -0,0 +1,143 @@
+package blocktrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const MessageType_Error MessageType = "error"
+
+var ErrWebsocketNotInitialized = errors.New("websocket not initialized")
+
+// WebsocketCommandError is returned when the server answers a websocket
+// command with an error message.
+type WebsocketCommandError struct {
+	Command string
+	Message string
+}
+
+func (e *WebsocketCommandError) Error() string {
+	return fmt.Sprintf("websocket command %v rejected: %v", e.Command, e.Message)
+}
+
+func (a *APIClient) writeWsCommand(command string, params map[string]interface{}) error {
+	ws := a.currentWs()
+	if ws == nil {
+		return ErrWebsocketNotInitialized
+	}
+
+	message := map[string]interface{}{
+		command: params,
+	}
+
+	return ws.writeJSON(message)
+}
+
+// writeWsCommandAck writes command and waits until the server acknowledges
+// it, rejects it or ctx is done.
+//
+// The websocket API documentation does not describe command replies. The
+// formats matched by resolveWsAck are assumptions this client makes and are
+// unverified. If the server does not reply that way, the call waits until
+// ctx is done, so ctx should always carry a deadline.
+func (a *APIClient) writeWsCommandAck(ctx context.Context, command string, params map[string]interface{}) error {
+	ackChan := a.addWsAckWaiter(command)
+	err := a.writeWsCommand(command, params)
+	if err != nil {
+		a.removeWsAckWaiter(command, ackChan)
+		return err
+	}
+
+	select {
+	case err := <-ackChan:
+		return err
+	case <-ctx.Done():
+		a.removeWsAckWaiter(command, ackChan)
+		return ctx.Err()
+	}
+}
+
+func (a *APIClient) addWsAckWaiter(command string) chan error {
+	ackChan := make(chan error, 1)
+
+	a.wsAckMtx.Lock()
+	a.wsAckWaiters[command] = append(a.wsAckWaiters[command], ackChan)
+	a.wsAckMtx.Unlock()
+
+	return ackChan
+}
+
+func (a *APIClient) removeWsAckWaiter(command string, ackChan chan error) {
+	a.wsAckMtx.Lock()
+	defer a.wsAckMtx.Unlock()
+
+	waiters := a.wsAckWaiters[command]
+	for i, c := range waiters {
+		if c == ackChan {
+			a.wsAckWaiters[command] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+
+	if len(a.wsAckWaiters[command]) == 0 {
+		delete(a.wsAckWaiters, command)
+	}
+}
+
+// resolveWsAck completes the oldest pending command matching wsMsg. It
+// assumes replies carry the command name as message_type and errors carry
+// it in the payload, see writeWsCommandAck.
+// Errors naming no command fail every pending command. It reports whether
+// wsMsg was consumed as a reply.
+func (a *APIClient) resolveWsAck(wsMsg *blocktradeWebsocketMessage) bool {
+	a.wsAckMtx.Lock()
+	defer a.wsAckMtx.Unlock()
+
+	if wsMsg.MessageType != MessageType_Error {
+		return a.popWsAckWaiter(string(wsMsg.MessageType), nil)
+	}
+
+	message, _ := wsMsg.Payload["message"].(string)
+	if command, ok := wsMsg.Payload["command"].(string); ok {
+		return a.popWsAckWaiter(command, &WebsocketCommandError{Command: command, Message: message})
+	}
+
+	handled := false
+	for command := range a.wsAckWaiters {
+		for a.popWsAckWaiter(command, &WebsocketCommandError{Command: command, Message: message}) {
+			handled = true
+		}
+	}
+
+	return handled
+}
+
+func (a *APIClient) popWsAckWaiter(command string, err error) bool {
+	waiters := a.wsAckWaiters[command]
+	if len(waiters) == 0 {
+		return false
+	}
+
+	waiters[0] <- err
+	if len(waiters) == 1 {
+		delete(a.wsAckWaiters, command)
+	} else {
+		a.wsAckWaiters[command] = waiters[1:]
+	}
+
+	return true
+}
+
+// failWsAckWaiters fails every pending command, e.g. when the connection is lost.
+func (a *APIClient) failWsAckWaiters(err error) {
+	a.wsAckMtx.Lock()
+	defer a.wsAckMtx.Unlock()
+
+	for command, waiters := range a.wsAckWaiters {
+		for _, c := range waiters {
+			c <- err
+		}
+		delete(a.wsAckWaiters, command)
+	}
+}
@@ -0,0 +1,200 @@
+package blocktrade
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const WS_WRITE_TIMEOUT = 10 * time.Second
+const WS_PING_INTERVAL = 30 * time.Second
+const WS_PONG_TIMEOUT = 90 * time.Second
+
+var ErrWebsocketClosed = errors.New("websocket closed")
+var ErrWebsocketStale = errors.New("websocket stale")
+
+type wsWriteRequest struct {
+	messageType int
+	data        []byte
+	json        interface{}
+	result      chan error
+}
+
+// wsSession owns a single websocket connection. All writes go through the
+// writer goroutine since gorilla/websocket allows only one concurrent writer.
+type wsSession struct {
+	conn      *websocket.Conn
+	writeChan chan wsWriteRequest
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	// pongTimeout is how long the connection may stay silent, neither
+	// answering pings nor sending messages, before it is considered dead.
+	pongTimeout time.Duration
+	lastSeen    int64
+}
+
+func newWsSession(conn *websocket.Conn, pongTimeout time.Duration) *wsSession {
+	s := &wsSession{
+		conn:        conn,
+		writeChan:   make(chan wsWriteRequest),
+		done:        make(chan struct{}),
+		pongTimeout: pongTimeout,
+	}
+
+	s.touch()
+	conn.SetPongHandler(func(string) error {
+		s.touch()
+		return nil
+	})
+
+	return s
+}
+
+// touch records activity on the connection and extends the read deadline.
+// It is only called from the read goroutine.
+func (s *wsSession) touch() {
+	now := time.Now()
+	atomic.StoreInt64(&s.lastSeen, now.UnixNano())
+	if s.pongTimeout > 0 {
+		s.conn.SetReadDeadline(now.Add(s.pongTimeout))
+	}
+}
+
+func (s *wsSession) lastSeenTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastSeen))
+}
+
+// read returns the next message, reporting an expired read deadline as
+// ErrWebsocketStale.
+func (s *wsSession) read() (int, []byte, error) {
+	t, msg, err := s.conn.ReadMessage()
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return t, msg, fmt.Errorf("%w: no message or pong for %v", ErrWebsocketStale, s.pongTimeout)
+		}
+		return t, msg, err
+	}
+
+	s.touch()
+	return t, msg, nil
+}
+
+// goroutine runs f as part of the session so that close can wait for it.
+func (s *wsSession) goroutine(f func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		f()
+	}()
+}
+
+func (s *wsSession) writeLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case req := <-s.writeChan:
+			s.conn.SetWriteDeadline(time.Now().Add(WS_WRITE_TIMEOUT))
+
+			var err error
+			if req.json != nil {
+				err = s.conn.WriteJSON(req.json)
+			} else {
+				err = s.conn.WriteMessage(req.messageType, req.data)
+			}
+
+			req.result <- err
+		}
+	}
+}
+
+func (s *wsSession) write(req wsWriteRequest) error {
+	req.result = make(chan error, 1)
+
+	select {
+	case <-s.done:
+		return ErrWebsocketClosed
+	case s.writeChan <- req:
+	}
+
+	select {
+	case <-s.done:
+		return ErrWebsocketClosed
+	case err := <-req.result:
+		return err
+	}
+}
+
+func (s *wsSession) writeJSON(v interface{}) error {
+	return s.write(wsWriteRequest{json: v})
+}
+
+func (s *wsSession) writeMessage(messageType int, data []byte) error {
+	return s.write(wsWriteRequest{messageType: messageType, data: data})
+}
+
+// shutdown stops the writer and closes the connection, which in turn ends
+// the read loop. It is safe to call from session goroutines.
+func (s *wsSession) shutdown() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.conn.Close()
+	})
+}
+
+// close shuts the session down and waits for its goroutines to exit.
+func (s *wsSession) close() {
+	s.shutdown()
+	s.wg.Wait()
+}
+
+func (a *APIClient) currentWs() *wsSession {
+	a.wsMtx.Lock()
+	defer a.wsMtx.Unlock()
+
+	return a.ws
+}
+
+// SetWebsocketHeartbeat configures the ping interval and how long the
+// websocket may stay silent before it is closed with ErrWebsocketStale.
+// A zero value disables pinging or the timeout respectively. It applies to
+// connections opened afterwards by Websocket.
+func (a *APIClient) SetWebsocketHeartbeat(pingInterval, pongTimeout time.Duration) {
+	a.wsMtx.Lock()
+	a.wsPingInterval = pingInterval
+	a.wsPongTimeout = pongTimeout
+	a.wsMtx.Unlock()
+}
+
+// WebsocketLastSeen returns when the current websocket last received a
+// message or pong. It returns the zero time if there is no connection.
+func (a *APIClient) WebsocketLastSeen() time.Time {
+	ws := a.currentWs()
+	if ws == nil {
+		return time.Time{}
+	}
+
+	return ws.lastSeenTime()
+}
+
+func (a *APIClient) closeWs() {
+	a.wsMtx.Lock()
+	ws := a.ws
+	a.ws = nil
+	if a.pingCancel != nil {
+		a.pingCancel()
+		a.pingCancel = nil
+	}
+	a.wsMtx.Unlock()
+
+	if ws != nil {
+		ws.close()
+	}
+}
@@ -0,0 +1,195 @@
+package blocktrade
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestWsServer starts a websocket server that answers pings, discards
+// commands and pushes ticker messages until the connection closes. It
+// returns the number of open connections.
+func newTestWsServer(t *testing.T) *int64 {
+	var open int64
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		atomic.AddInt64(&open, 1)
+		defer atomic.AddInt64(&open, -1)
+
+		var writeMtx sync.Mutex
+		conn.SetPingHandler(func(data string) error {
+			writeMtx.Lock()
+			defer writeMtx.Unlock()
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				writeMtx.Lock()
+				err := conn.WriteMessage(websocket.TextMessage, []byte(`{"message_type":"ticker","payload":{"trading_pair_id":1}}`))
+				writeMtx.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	url := WS_URL
+	WS_URL = "ws" + strings.TrimPrefix(server.URL, "http")
+	t.Cleanup(func() { WS_URL = url })
+
+	return &open
+}
+
+// waitClosed fails the test if the server still has open connections after
+// the client closed.
+func waitClosed(t *testing.T, open *int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(open) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%v connections left open", atomic.LoadInt64(open))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestWebsocketConcurrency writes, pings, reconnects and closes the
+// websocket from several goroutines at once. Run it with -race.
+func TestWebsocketConcurrency(t *testing.T) {
+	open := newTestWsServer(t)
+
+	client := NewClient("", "")
+	client.SetWebsocketHeartbeat(time.Millisecond, time.Second)
+	client.setWsHandler(MessageType_Ticker, TickerHandlerFunc(func(*TickerResponse, error) {}))
+	client.OnRawMessage(func(MessageType, []byte) {})
+	defer client.Close()
+
+	for i := 0; i < 10; i++ {
+		_, err := client.Websocket()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				for k := 0; k < 20; k++ {
+					switch (j + k) % 4 {
+					case 0:
+						client.UnsubscribeTicker(int64(k))
+					case 1:
+						client.UnsubscribeUserOrders()
+					case 2:
+						client.StartPing(time.Millisecond)
+					case 3:
+						client.WebsocketLastSeen()
+					}
+				}
+			}(j)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * time.Millisecond)
+			client.Close()
+		}()
+
+		wg.Wait()
+		client.Close()
+	}
+
+	err := client.UnsubscribeTicker(1)
+	if err != ErrWebsocketNotInitialized {
+		t.Fatalf("expected %v after close, got %v", ErrWebsocketNotInitialized, err)
+	}
+
+	waitClosed(t, open)
+}
+
+// TestWebsocketConcurrentConnect connects from several goroutines at once
+// while closing, no session may be left running.
+func TestWebsocketConcurrentConnect(t *testing.T) {
+	open := newTestWsServer(t)
+
+	client := NewClient("", "")
+	client.SetWebsocketHeartbeat(time.Millisecond, time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if (i+j)%3 == 0 {
+					client.Close()
+					continue
+				}
+
+				_, err := client.Websocket()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				client.UnsubscribeTicker(int64(j))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	client.Close()
+
+	waitClosed(t, open)
+}
+
+// TestWebsocketWriteAfterClose checks that writes on a closed session fail
+// instead of blocking.
+func TestWebsocketWriteAfterClose(t *testing.T) {
+	newTestWsServer(t)
+
+	client := NewClient("", "")
+	_, err := client.Websocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := client.currentWs()
+	client.Close()
+
+	err = ws.writeJSON(map[string]interface{}{})
+	if err != ErrWebsocketClosed {
+		t.Fatalf("expected %v, got %v", ErrWebsocketClosed, err)
+	}
+}